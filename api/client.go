@@ -0,0 +1,129 @@
+// package api provides methods for creating SFO Museum API clients shared by the handlers in this package.
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+)
+
+// NewClientWithQuery returns a new `sfomuseum/go-sfomuseum-api/v2/client.Client` instance derived from the following query parameters in 'q':
+// - `?token={TOKEN}` A valid SFO Museum API access token.
+// - `?api={URI}` An optional API endpoint to use instead of the default `https://api.sfomuseum.org/rest` endpoint. This may be a "https://"
+// URL, a "http://" URL for a loopback host (for example a local mock API server in CI) or a valid `sfomuseum/go-sfomuseum-api/v2/client`
+// URI (for example "oauth2://staging.example.com/rest").
+// - `?insecure={BOOLEAN}` An optional boolean flag signaling that TLS verification for the API endpoint should be skipped. This is
+// principally for testing against local (mock) API servers.
+//
+// Clients are created by the `sfomuseum/go-sfomuseum-api/v2/client` package and perform API requests using `http.DefaultTransport`. In order
+// for API requests to be retried if they fail with a transient error, or to return a `StatusError` for non-200 responses, a `Transport` instance
+// for the same endpoint must be installed as `http.DefaultTransport` (see `InstallTransportWithQuery`). Plain-text "http://" endpoints are only
+// supported if a `Transport` instance has been installed.
+func NewClientWithQuery(ctx context.Context, q url.Values) (client.Client, error) {
+
+	client_uri, err := ClientURIWithQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := EndpointWithQuery(q)
+
+	if err == nil && endpoint.Scheme == "http" {
+
+		_, installed := installedTransport(endpoint)
+
+		if !installed {
+			return nil, fmt.Errorf("Plain-text HTTP endpoints require an api.Transport instance for the endpoint to be installed as http.DefaultTransport")
+		}
+	}
+
+	api_client, err := client.NewClient(ctx, client_uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new client, %w", err)
+	}
+
+	return api_client, nil
+}
+
+// IsLoopbackHost returns a boolean value indicating whether 'host' (without a port) is "localhost" or a loopback IP address.
+func IsLoopbackHost(host string) bool {
+
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ClientURIWithQuery returns a `sfomuseum/go-sfomuseum-api/v2/client` URI derived from the query parameters in 'q'. See `NewClientWithQuery` for details.
+func ClientURIWithQuery(q url.Values) (string, error) {
+
+	client_u := &url.URL{
+		Scheme: "oauth2",
+	}
+
+	client_q := url.Values{}
+
+	if q.Has("api") {
+
+		api_u, err := url.Parse(q.Get("api"))
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to parse ?api= parameter, %w", err)
+		}
+
+		switch api_u.Scheme {
+		case "https":
+
+			client_u.Host = api_u.Host
+			client_u.Path = api_u.Path
+
+		case "":
+			return "", fmt.Errorf("Invalid ?api= parameter, missing scheme")
+
+		case "http":
+
+			// Plain-text HTTP endpoints are only supported for local (mock) API servers
+
+			if !IsLoopbackHost(api_u.Hostname()) {
+				return "", fmt.Errorf("Invalid ?api= parameter, plain-text HTTP endpoints are only supported for loopback hosts")
+			}
+
+			// The API requests for this endpoint are sent using plain-text HTTP by an installed `Transport` instance
+
+			client_u.Host = api_u.Host
+			client_u.Path = api_u.Path
+
+		default:
+
+			client_u = api_u
+			client_q = api_u.Query()
+		}
+	}
+
+	if q.Has("token") || !client_q.Has("access_token") {
+		client_q.Set("access_token", q.Get("token"))
+	}
+
+	if q.Has("insecure") {
+
+		v, err := strconv.ParseBool(q.Get("insecure"))
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to parse ?insecure= parameter, %w", err)
+		}
+
+		client_q.Set("insecure", strconv.FormatBool(v))
+	}
+
+	client_u.RawQuery = client_q.Encode()
+	return client_u.String(), nil
+}
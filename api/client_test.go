@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientURIWithQuery(t *testing.T) {
+
+	tests := map[string]string{
+		"token=TOKEN": "oauth2:?access_token=TOKEN",
+		"token=TOKEN&api=https://staging.example.com/rest":                               "oauth2://staging.example.com/rest?access_token=TOKEN",
+		"token=TOKEN&api=https://127.0.0.1:8443&insecure=1":                              "oauth2://127.0.0.1:8443?access_token=TOKEN&insecure=true",
+		"token=TOKEN&api=http://localhost:8080/rest":                                     "oauth2://localhost:8080/rest?access_token=TOKEN",
+		"api=" + url.QueryEscape("oauth2://mirror.example.com/rest?access_token=SECRET"): "oauth2://mirror.example.com/rest?access_token=SECRET",
+	}
+
+	for str_q, expected := range tests {
+
+		q, err := url.ParseQuery(str_q)
+
+		if err != nil {
+			t.Fatalf("Failed to parse query '%s', %v", str_q, err)
+		}
+
+		uri, err := ClientURIWithQuery(q)
+
+		if err != nil {
+			t.Fatalf("Failed to derive client URI for '%s', %v", str_q, err)
+		}
+
+		if uri != expected {
+			t.Fatalf("Unexpected client URI for '%s': %s", str_q, uri)
+		}
+	}
+
+	invalid := []string{
+		"api=http://api.example.com/rest",
+		"api=localhost",
+		"insecure=maybe",
+	}
+
+	for _, str_q := range invalid {
+
+		q, _ := url.ParseQuery(str_q)
+
+		_, err := ClientURIWithQuery(q)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str_q)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+
+	ctx := context.Background()
+
+	var count atomic.Int32
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if req.Header.Get("Authorization") != "Bearer VE9LRU4=" {
+			rsp.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if req.URL.Path != "/rest" {
+			rsp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch req.URL.Query().Get("method") {
+		case "api.test.echo":
+
+			if count.Add(1) == 1 {
+				rsp.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			rsp.Write([]byte(`{"stat":"ok"}`))
+
+		default:
+			rsp.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL+"/rest")
+
+	transport_q := url.Values{}
+	transport_q.Set("api", server.URL+"/rest")
+	transport_q.Set("insecure", "true")
+	transport_q.Set("backoff", "1ms")
+
+	restore, err := InstallTransportWithQuery(transport_q)
+
+	if err != nil {
+		t.Fatalf("Failed to install transport, %v", err)
+	}
+
+	defer restore()
+
+	cl, err := NewClientWithQuery(ctx, q)
+
+	if err != nil {
+		t.Fatalf("Failed to create client, %v", err)
+	}
+
+	args := &url.Values{}
+	args.Set("method", "api.test.echo")
+
+	r, err := cl.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+		t.Fatalf("Failed to execute method, %v", err)
+	}
+
+	r.Close()
+
+	if count.Load() != 2 {
+		t.Fatalf("Expected request to be retried, %d attempts", count.Load())
+	}
+
+	args.Set("method", "api.test.missing")
+
+	_, err = cl.ExecuteMethod(ctx, http.MethodGet, args)
+
+	var status_err *StatusError
+
+	if !errors.As(err, &status_err) || status_err.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status error, %v", err)
+	}
+
+	restore()
+
+	// Plain-text HTTP endpoints for loopback hosts
+
+	plain_server := httptest.NewServer(http.HandlerFunc(handler))
+	defer plain_server.Close()
+
+	q.Set("api", plain_server.URL+"/rest")
+
+	_, err = NewClientWithQuery(ctx, q)
+
+	if err == nil {
+		t.Fatalf("Expected plain-text endpoint without an installed transport to fail")
+	}
+
+	transport_q.Set("api", plain_server.URL+"/rest")
+	transport_q.Del("insecure")
+
+	restore, err = InstallTransportWithQuery(transport_q)
+
+	if err != nil {
+		t.Fatalf("Failed to install transport for plain-text endpoint, %v", err)
+	}
+
+	defer restore()
+
+	cl, err = NewClientWithQuery(ctx, q)
+
+	if err != nil {
+		t.Fatalf("Failed to create client for plain-text endpoint, %v", err)
+	}
+
+	args.Set("method", "api.test.echo")
+
+	r, err = cl.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+		t.Fatalf("Failed to execute method against plain-text endpoint, %v", err)
+	}
+
+	r.Close()
+}
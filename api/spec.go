@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/response"
+)
+
+// SPEC_METHODS is the API method which returns the specifications for all the API methods available to a client.
+const SPEC_METHODS string = "api.spec.methods"
+
+// specIgnoreParameters are the parameters which are handled by the API itself, rather than individual API methods, and
+// are not listed in the specifications for API methods.
+var specIgnoreParameters = []string{
+	"method",
+	"access_token",
+	"page",
+	"per_page",
+}
+
+// MethodsResponse is the response returned by the `api.spec.methods` API method.
+type MethodsResponse struct {
+	// The specifications for all the API methods available to the client.
+	Methods []*response.Method `json:"methods"`
+}
+
+// Methods returns the specifications, keyed by method name, for all the API methods available to 'api_client' using
+// the `api.spec.methods` API method.
+func Methods(ctx context.Context, api_client client.Client) (map[string]*response.Method, error) {
+
+	args := &url.Values{}
+	args.Set("method", SPEC_METHODS)
+
+	rsp, err := api_client.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to execute %s method, %w", SPEC_METHODS, err)
+	}
+
+	defer rsp.Close()
+
+	var methods_rsp *MethodsResponse
+
+	dec := json.NewDecoder(rsp)
+	err = dec.Decode(&methods_rsp)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal %s response, %w", SPEC_METHODS, err)
+	}
+
+	methods := make(map[string]*response.Method)
+
+	if methods_rsp == nil {
+		return methods, nil
+	}
+
+	for _, m := range methods_rsp.Methods {
+		methods[m.Name] = m
+	}
+
+	return methods, nil
+}
+
+// ValidateMethod ensures that the API method defined by the "method" parameter in 'args' is available to 'api_client', that all
+// of its required parameters are present in 'args' and that all of the other parameters in 'args' are supported by the method,
+// according to the specifications returned by the `api.spec.methods` API method. API methods ignore parameters they don't support
+// so this is used to reject arguments (for example search facets) which would otherwise be silently ignored.
+func ValidateMethod(ctx context.Context, api_client client.Client, args *url.Values) error {
+
+	methods, err := Methods(ctx, api_client)
+
+	if err != nil {
+		return err
+	}
+
+	name := args.Get("method")
+
+	m, exists := methods[name]
+
+	if !exists {
+		return fmt.Errorf("The %s method is not available", name)
+	}
+
+	params := make([]string, len(m.Parameters))
+
+	for idx, p := range m.Parameters {
+
+		params[idx] = p.Name
+
+		if p.Required && !args.Has(p.Name) {
+			return fmt.Errorf("Missing required parameter '%s' for %s method", p.Name, name)
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(*args)) {
+
+		if slices.Contains(specIgnoreParameters, k) {
+			continue
+		}
+
+		if !slices.Contains(params, k) {
+			return fmt.Errorf("Unsupported parameter '%s' for %s method", k, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestValidateMethod(t *testing.T) {
+
+	ctx := context.Background()
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		body := map[string]any{
+			"stat": "ok",
+			"methods": []any{
+				map[string]any{
+					"name":           "sfomuseum.collection.objects.search",
+					"request_method": "GET",
+					"parameters": []any{
+						map[string]any{"name": "q", "required": true},
+						map[string]any{"name": "medium"},
+					},
+				},
+			},
+		}
+
+		rsp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rsp).Encode(body)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+
+	restore, err := InstallTransportWithQuery(q)
+
+	if err != nil {
+		t.Fatalf("Failed to install transport, %v", err)
+	}
+
+	defer restore()
+
+	cl, err := NewClientWithQuery(ctx, q)
+
+	if err != nil {
+		t.Fatalf("Failed to create client, %v", err)
+	}
+
+	tests := map[string]bool{
+		"method=sfomuseum.collection.objects.search&q=747&medium=postcard&page=2": true,
+		"method=sfomuseum.collection.objects.search&medium=postcard":              false,
+		"method=sfomuseum.collection.objects.search&q=747&decade=1950":            false,
+		"method=sfomuseum.collection.objects.getInfo&object_id=101":               false,
+	}
+
+	for str_args, expected := range tests {
+
+		args, _ := url.ParseQuery(str_args)
+
+		err := ValidateMethod(ctx, cl, &args)
+
+		if (err == nil) != expected {
+			t.Fatalf("Unexpected result validating '%s', %v", str_args, err)
+		}
+	}
+}
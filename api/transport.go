@@ -0,0 +1,221 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/transport"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+)
+
+// StatusError is the error returned by a `Transport` instance when an API request fails with a non-200 HTTP status code.
+type StatusError struct {
+	// The HTTP status code returned by the API.
+	StatusCode int
+	// The HTTP status returned by the API.
+	Status string
+}
+
+// Error returns a string representation of the error. This is the same string returned by the `sfomuseum/go-sfomuseum-api/v2/client.OAuth2Client`
+// implementation for non-200 responses.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API call failed with status '%s'", e.Status)
+}
+
+// Transport implements the `http.RoundTripper` interface for requests to a SFO Museum API endpoint. The `sfomuseum/go-sfomuseum-api/v2/client.OAuth2Client`
+// implementation performs API requests using `http.DefaultTransport` so applications install a `Transport` instance as `http.DefaultTransport` (see
+// `InstallTransportWithQuery`) in order for API requests to be:
+// - Retried, rate limited and subject to the timeouts, proxy, certificate authorities and User-Agent header described in `transport.OptionsWithQuery`.
+// - Sent to a plain-text "http://" endpoint for a loopback host (for example a local mock API server in CI). The `OAuth2Client` implementation
+// always uses "https://" endpoints.
+// - Failed with a `StatusError` if the API returns a non-200 HTTP status code, rather than the untyped error returned by the `OAuth2Client`
+// implementation. Since the `OAuth2Client` implementation wraps the errors returned by its `http.Client` these can be tested for using `errors.As`.
+//
+// Requests for any other host are performed by the transport that the `Transport` instance replaced. Note that the `OAuth2Client` implementation uses
+// its own transport, bypassing `http.DefaultTransport`, when it is created with the `?insecure=` parameter so skipping TLS verification for an installed
+// `Transport` instance is configured when the transport is created instead.
+type Transport struct {
+	http.RoundTripper
+	// endpoint is the API endpoint whose requests are performed by 'api_transport'.
+	endpoint *url.URL
+	// api_transport is the `transport.RetryTransport` instance used to perform API requests.
+	api_transport http.RoundTripper
+	// default_transport is the `http.RoundTripper` instance used to perform all other requests.
+	default_transport http.RoundTripper
+}
+
+// NewTransportWithQuery returns a new `Transport` instance for the API endpoint defined by the `?api=` parameter in 'q' (or the default
+// `https://api.sfomuseum.org/rest` endpoint) and the following query parameters:
+// - `?insecure={BOOLEAN}` An optional boolean flag signaling that TLS verification for the API endpoint should be skipped. This is principally
+// for testing against local (mock) API servers.
+// - Any of the retry, rate limiting, timeout, proxy, certificate authority and User-Agent parameters described in `transport.OptionsWithQuery`.
+//
+// Requests for any other host are performed by 'default_transport'.
+func NewTransportWithQuery(q url.Values, default_transport http.RoundTripper) (*Transport, error) {
+
+	endpoint, err := EndpointWithQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := transport.OptionsWithQuery(q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive transport options, %w", err)
+	}
+
+	if q.Has("insecure") {
+
+		v, err := strconv.ParseBool(q.Get("insecure"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?insecure= parameter, %w", err)
+		}
+
+		opts.Insecure = v
+	}
+
+	t := &Transport{
+		endpoint:          endpoint,
+		api_transport:     transport.NewRetryTransport(opts),
+		default_transport: default_transport,
+	}
+
+	return t, nil
+}
+
+// InstallTransportWithQuery creates a new `Transport` instance derived from 'q' (see `NewTransportWithQuery`) and installs it as `http.DefaultTransport`.
+// It returns a function which restores the previous `http.DefaultTransport` instance.
+func InstallTransportWithQuery(q url.Values) (func(), error) {
+
+	previous := http.DefaultTransport
+
+	t, err := NewTransportWithQuery(q, previous)
+
+	if err != nil {
+		return nil, err
+	}
+
+	http.DefaultTransport = t
+
+	restore := func() {
+		http.DefaultTransport = previous
+	}
+
+	return restore, nil
+}
+
+// RoundTrip performs 'req' using the API transport if it is a request for the API endpoint host and the default transport otherwise.
+// API requests which return a non-200 HTTP status code return a `StatusError`.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	if req.URL.Host != t.endpoint.Host {
+		return t.default_transport.RoundTrip(req)
+	}
+
+	api_req := req.Clone(req.Context())
+	api_req.URL.Scheme = t.endpoint.Scheme
+
+	// The OAuth2Client implementation joins the host and path of an endpoint with an extra "/"
+
+	api_req.URL.Path = "/" + strings.TrimLeft(api_req.URL.Path, "/")
+
+	rsp, err := t.api_transport.RoundTrip(api_req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+
+		// Drain (some of) the body so the underlying connection can be reused
+		io.CopyN(io.Discard, rsp.Body, 4096)
+		rsp.Body.Close()
+
+		return nil, &StatusError{StatusCode: rsp.StatusCode, Status: rsp.Status}
+	}
+
+	return rsp, nil
+}
+
+// installedTransport returns the `Transport` instance installed as `http.DefaultTransport` for the API endpoint 'endpoint', if present.
+func installedTransport(endpoint *url.URL) (*Transport, bool) {
+
+	t, ok := http.DefaultTransport.(*Transport)
+
+	if !ok || t.endpoint.Host != endpoint.Host {
+		return nil, false
+	}
+
+	return t, true
+}
+
+// EndpointWithQuery returns the URL of the API endpoint defined by the `?api=` parameter in 'q' or the default `https://api.sfomuseum.org/rest`
+// endpoint if it is not present. See `NewClientWithQuery` for details.
+func EndpointWithQuery(q url.Values) (*url.URL, error) {
+
+	if !q.Has("api") {
+		return url.Parse(client.API_ENDPOINT)
+	}
+
+	api_u, err := url.Parse(q.Get("api"))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse ?api= parameter, %w", err)
+	}
+
+	switch api_u.Scheme {
+	case "https":
+
+		endpoint := &url.URL{
+			Scheme: api_u.Scheme,
+			Host:   api_u.Host,
+			Path:   api_u.Path,
+		}
+
+		return endpoint, nil
+
+	case "http":
+
+		// Plain-text HTTP endpoints are only supported for local (mock) API servers
+
+		if !IsLoopbackHost(api_u.Hostname()) {
+			return nil, fmt.Errorf("Invalid ?api= parameter, plain-text HTTP endpoints are only supported for loopback hosts")
+		}
+
+		endpoint := &url.URL{
+			Scheme: api_u.Scheme,
+			Host:   api_u.Host,
+			Path:   api_u.Path,
+		}
+
+		return endpoint, nil
+
+	case "oauth2":
+
+		switch api_u.Host {
+		case "", "api":
+			return url.Parse(client.API_ENDPOINT)
+		default:
+
+			endpoint := &url.URL{
+				Scheme: "https",
+				Host:   api_u.Host,
+				Path:   api_u.Path,
+			}
+
+			return endpoint, nil
+		}
+
+	case "":
+		return nil, fmt.Errorf("Invalid ?api= parameter, missing scheme")
+
+	default:
+		return nil, fmt.Errorf("Invalid ?api= parameter, unsupported scheme '%s'", api_u.Scheme)
+	}
+}
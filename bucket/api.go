@@ -0,0 +1,433 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	pb_bucket "github.com/aaronland/go-picturebook/bucket"
+	"github.com/jtacoma/uritemplates"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/media"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+	"github.com/tidwall/gjson"
+)
+
+// API_IDS_OBJECT signals that the IDs returned by an API method are object IDs.
+const API_IDS_OBJECT string = "object"
+
+// API_IDS_IMAGE signals that the IDs returned by an API method are image IDs.
+const API_IDS_IMAGE string = "image"
+
+// API_SHOEBOX_PREFIX is the prefix for `api://` bucket URI parameters which are passed, with the prefix removed, to the `shoebox://`
+// bucket (and the resolvers it creates) rather than as arguments to the API method. For example `?shoebox_limit=10`.
+const API_SHOEBOX_PREFIX string = "shoebox_"
+
+// apiBucketParameters are the `api://` bucket URI parameters which are specific to the `api://` bucket and are never passed as
+// arguments to the API method.
+var apiBucketParameters = []string{
+	"method",
+	"path",
+	"ids",
+	"uri_template",
+	"paginated",
+}
+
+// APIBucket implements the `aaronland/go-picturebook/bucket.Bucket` interface for use with the images for the objects, or the
+// images, returned by an arbitrary SFO Museum API method.
+type APIBucket struct {
+	*ShoeboxBucket
+	method_args  *url.Values
+	path         string
+	ids          string
+	uri_template *uritemplates.UriTemplate
+	paginated    bool
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := registerBucket(ctx, "api", NewAPIBucket)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewAPIBucket returns a new `APIBucket` instance implementing the `aaronland/go-picturebook/bucket.Bucket` interface for use with
+// the images for the objects, or the images, returned by an arbitrary SFO Museum API method. 'uri' is expected to take the form of:
+//
+//	api://?method={METHOD}&path={PATH}&{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?method={METHOD}` The name of the SFO Museum API method to call. Required.
+// - `?path={PATH}` The path to the object or image IDs in the API response (for example "objects.#.wof:id"). See https://github.com/tidwall/gjson
+// for details of the path syntax. The path may resolve to a single ID or a list of IDs. Required.
+// - `?ids={TYPE}` The type of IDs returned by the API method. Valid options are "object" or "image". Default is "object".
+// - `?uri_template={TEMPLATE}` A RFC 6570 URI template used to derive the URI for an image from its ID (for example
+// "https://static.sfomuseum.org/media/{id}.jpg"). Required if `?ids=image`.
+// - `?paginated={BOOLEAN}` A boolean flag signaling whether the API method returns paginated results. Default is true.
+// - Any of the parameters for the `shoebox://` bucket, except those used to select shoebox items, for the API endpoint, HTTP requests,
+// resolving images, error handling, reports, deduplication, checkpoints and caching prefixed with "shoebox_" (see `API_SHOEBOX_PREFIX`),
+// for example `?shoebox_token={TOKEN}` or `?shoebox_limit={N}` for the maximum number of IDs to include. See `NewShoeboxBucket` for details.
+// The `?shoebox_status=`, `?shoebox_sample=` and `?shoebox_seed=` parameters, which only apply to shoebox items, are reported as an error.
+// - Any other parameter is passed as an argument to the API method.
+//
+// Object IDs are resolved in the same way as objects in a shoebox. Image URIs derived from `?uri_template=` must be associated with one
+// of the bucket's media hosts. In both cases the `shoebox://` caption can be used with the pictures the bucket yields.
+func NewAPIBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	method := q.Get("method")
+
+	if method == "" {
+		return nil, fmt.Errorf("Missing ?method= parameter")
+	}
+
+	path := q.Get("path")
+
+	if path == "" {
+		return nil, fmt.Errorf("Missing ?path= parameter")
+	}
+
+	method_args := &url.Values{}
+
+	for k, v := range q {
+
+		if slices.Contains(apiBucketParameters, k) {
+			continue
+		}
+
+		_, is_shoebox := shoeboxParameterName(u, k)
+
+		if is_shoebox {
+			continue
+		}
+
+		(*method_args)[k] = v
+	}
+
+	method_args.Set("method", method)
+
+	err = rejectShoeboxSelectParameters(shoeboxParameters(u))
+
+	if err != nil {
+		return nil, err
+	}
+
+	shoebox_b, err := newShoeboxBucket(ctx, u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b := &APIBucket{
+		ShoeboxBucket: shoebox_b,
+		method_args:   method_args,
+		path:          path,
+		ids:           API_IDS_OBJECT,
+		paginated:     true,
+	}
+
+	if q.Has("ids") {
+
+		v := strings.ToLower(q.Get("ids"))
+
+		switch v {
+		case API_IDS_OBJECT, API_IDS_IMAGE:
+			b.ids = v
+		default:
+			return nil, fmt.Errorf("Invalid ?ids= parameter, must be '%s' or '%s'", API_IDS_OBJECT, API_IDS_IMAGE)
+		}
+	}
+
+	if q.Has("uri_template") {
+
+		t, err := uritemplates.Parse(q.Get("uri_template"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?uri_template= parameter, %w", err)
+		}
+
+		b.uri_template = t
+	}
+
+	if b.ids == API_IDS_IMAGE && b.uri_template == nil {
+		return nil, fmt.Errorf("Missing ?uri_template= parameter, required for image IDs")
+	}
+
+	if q.Has("paginated") {
+
+		v, err := strconv.ParseBool(q.Get("paginated"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?paginated= parameter, %w", err)
+		}
+
+		b.paginated = v
+	}
+
+	return b, nil
+}
+
+// GatherPictures returns a new `iter.Seq2[string, error]` instance containing the URIs for the images of the objects, or the images,
+// returned by the bucket's API method. IDs which can not be resolved are handled, reported and checkpointed in the same way as shoebox
+// items. See `ShoeboxBucket.GatherPictures` for details.
+func (b *APIBucket) GatherPictures(ctx context.Context, uris ...string) iter.Seq2[string, error] {
+	return b.gather(ctx, b.methodItems)
+}
+
+// methodItems is a `shoeboxItemSource` for the IDs returned by the bucket's API method.
+func (b *APIBucket) methodItems(ctx context.Context) (map[uint8]shoebox.Resolver, shoeboxItemsLister, error) {
+
+	if b.ids == API_IDS_IMAGE {
+
+		r := &imageTemplateResolver{
+			uri_template: b.uri_template,
+			media_hosts:  b.media_hosts,
+			captions:     b.resolvers["object"],
+		}
+
+		resolvers := map[uint8]shoebox.Resolver{
+			0: r,
+		}
+
+		return resolvers, executeMethodItems(ctx, b.api_client, b.method_args, b.paginated, b.path, 0, b.items_opts.limit), nil
+	}
+
+	resolvers, type_id, err := b.objectResolvers(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resolvers, executeMethodItems(ctx, b.api_client, b.method_args, b.paginated, b.path, type_id, b.items_opts.limit), nil
+}
+
+// executeMethodItems returns a `shoeboxItemsLister` for batches of shoebox items, one batch per page of results returned by the API method
+// defined in 'args'. Each ID found at 'path' (see https://github.com/tidwall/gjson for details of the path syntax) in a page of
+// results is represented by a shoebox item whose type is 'type_id' and whose ID and item ID are both that ID. If 'paginated' is
+// false the API method is only called once. If 'limit' is greater than zero no further pages of results are requested once that
+// many IDs have been returned. Page numbers are relative to the first page of results requested by 'args'.
+func executeMethodItems(ctx context.Context, api_client client.Client, args *url.Values, paginated bool, path string, type_id uint8, limit int) shoeboxItemsLister {
+
+	return func(start int, count int) iter.Seq2[*shoeboxItemsPage, error] {
+
+		return func(yield func(*shoeboxItemsPage, error) bool) {
+
+			method_args := &url.Values{}
+
+			for k, v := range *args {
+				(*method_args)[k] = v
+			}
+
+			method := method_args.Get("method")
+
+			// The number of the last page of results and the number of IDs included so far
+			page := start - 1
+			included := count
+
+			if !paginated {
+				page = 0
+				included = 0
+			}
+
+			if page > 0 {
+
+				first := 1
+
+				if method_args.Has("page") {
+
+					v, err := strconv.Atoi(method_args.Get("page"))
+
+					if err != nil {
+						yield(nil, fmt.Errorf("Failed to parse page number for %s method, %w", method, err))
+						return
+					}
+
+					first = v
+				}
+
+				method_args.Set("page", strconv.Itoa(first+page))
+			}
+
+			var results iter.Seq2[io.ReadSeeker, error]
+
+			if paginated {
+				results = client.ExecuteMethodPaginatedWithClient(ctx, api_client, http.MethodGet, method_args)
+			} else {
+
+				results = func(yield func(io.ReadSeeker, error) bool) {
+
+					r, err := api_client.ExecuteMethod(ctx, http.MethodGet, method_args)
+
+					if err != nil {
+						yield(nil, err)
+						return
+					}
+
+					defer r.Close()
+
+					yield(r, nil)
+				}
+			}
+
+			for r, err := range results {
+
+				if err != nil {
+					yield(nil, fmt.Errorf("Failed to execute %s method, %w", method, err))
+					return
+				}
+
+				page += 1
+
+				body, err := io.ReadAll(r)
+
+				if err != nil {
+					yield(nil, fmt.Errorf("Failed to read %s response, %w", method, err))
+					return
+				}
+
+				ids, err := idsForPath(body, path)
+
+				if err != nil {
+					yield(nil, fmt.Errorf("Failed to derive IDs from %s response, %w", method, err))
+					return
+				}
+
+				items := make([]*response.ShoeboxListItem, 0)
+
+				for _, id := range ids {
+
+					i := &response.ShoeboxListItem{
+						Id:     id,
+						ItemId: id,
+						TypeId: type_id,
+					}
+
+					items = append(items, i)
+					included += 1
+
+					if limit > 0 && included >= limit {
+						break
+					}
+				}
+
+				if len(items) > 0 && !yield(&shoeboxItemsPage{page: page, items: items}, nil) {
+					return
+				}
+
+				if limit > 0 && included >= limit {
+					return
+				}
+			}
+		}
+	}
+}
+
+// idsForPath returns the list of (numeric) IDs found at 'path' in 'body'. Paths which don't exist in 'body' return an empty list.
+func idsForPath(body []byte, path string) ([]int64, error) {
+
+	ids := make([]int64, 0)
+
+	rsp := gjson.GetBytes(body, path)
+
+	if !rsp.Exists() {
+		return ids, nil
+	}
+
+	values := []gjson.Result{rsp}
+
+	if rsp.IsArray() {
+		values = rsp.Array()
+	}
+
+	for _, v := range values {
+
+		var id int64
+
+		switch v.Type {
+		case gjson.Number:
+			id = v.Int()
+		case gjson.String:
+
+			i, err := strconv.ParseInt(v.String(), 10, 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid ID '%s', %w", v.String(), err)
+			}
+
+			id = i
+		default:
+			return nil, fmt.Errorf("Invalid ID '%s', not a number", v.Raw)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// imageTemplateResolver implements the `shoebox.Resolver` interface for image IDs whose URIs are derived from a URI template.
+type imageTemplateResolver struct {
+	shoebox.Resolver
+	// uri_template is the URI template used to derive the URI for an image from its ID.
+	uri_template *uritemplates.UriTemplate
+	// media_hosts are the hosts with which image URIs must be associated.
+	media_hosts *media.Hosts
+	// captions is the resolver used to derive captions for the images.
+	captions shoebox.Resolver
+}
+
+// Resolve returns an iterator containing the key for the image whose ID is the item ID of 'i'.
+func (r *imageTemplateResolver) Resolve(ctx context.Context, i *response.ShoeboxListItem) iter.Seq2[string, error] {
+
+	return func(yield func(string, error) bool) {
+
+		vars := map[string]interface{}{
+			"id": strconv.FormatInt(i.ItemId, 10),
+		}
+
+		im_uri, err := r.uri_template.Expand(vars)
+
+		if err != nil {
+			yield("", fmt.Errorf("Failed to expand URI template for image %d, %w", i.ItemId, err))
+			return
+		}
+
+		if !r.media_hosts.IsValid(im_uri) {
+			yield("", fmt.Errorf("URI for image %d (%s) is not associated with a valid media host", i.ItemId, im_uri))
+			return
+		}
+
+		image_uri := keys.Encode(&keys.ShoeboxKey{
+			URI:     im_uri,
+			Label:   "o",
+			Id:      i.Id,
+			ImageId: i.ItemId,
+			Type:    API_IDS_IMAGE,
+		})
+
+		yield(image_uri, nil)
+	}
+}
+
+// Caption returns the caption text for the image identified by 'key'.
+func (r *imageTemplateResolver) Caption(ctx context.Context, key string) (string, error) {
+	return r.captions.Caption(ctx, key)
+}
@@ -0,0 +1,140 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestIdsForPath(t *testing.T) {
+
+	body := []byte(`{"objects":[{"wof:id":101},{"wof:id":"102"}],"image":{"id":1011},"invalid":[{"wof:id":true}]}`)
+
+	tests := map[string][]int64{
+		"objects.#.wof:id": []int64{101, 102},
+		"image.id":         []int64{1011},
+		"missing":          []int64{},
+	}
+
+	for path, expected := range tests {
+
+		ids, err := idsForPath(body, path)
+
+		if err != nil {
+			t.Fatalf("Failed to derive IDs for '%s', %v", path, err)
+		}
+
+		if !slices.Equal(ids, expected) {
+			t.Fatalf("Unexpected IDs for '%s': %v", path, ids)
+		}
+	}
+
+	_, err := idsForPath(body, "invalid.#.wof:id")
+
+	if err == nil {
+		t.Fatalf("Expected invalid IDs to fail")
+	}
+}
+
+func TestAPIBucket(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	methods["sfomuseum.collection.objects.getRandom"] = func(q url.Values) (map[string]any, error) {
+
+		if q.Get("count") != "2" || q.Get("limit") != "10" || q.Get("status") != "1" || q.Has("images") || q.Has("shoebox_images") {
+			return nil, fmt.Errorf("Unexpected arguments: %v", q)
+		}
+
+		return map[string]any{
+			"objects": []any{
+				map[string]any{"wof:id": 102},
+				map[string]any{"wof:id": 101},
+			},
+		}, nil
+	}
+
+	methods["sfomuseum.collection.images.search"] = func(q url.Values) (map[string]any, error) {
+		return map[string]any{
+			"_results": "images",
+			"images": []any{
+				map[string]any{"id": 1011},
+				map[string]any{"id": 1012},
+				map[string]any{"id": 1021},
+			},
+		}, nil
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("shoebox_token", "TOKEN")
+	q.Set("shoebox_api", server.URL)
+
+	tests := map[string][]string{
+		"method=sfomuseum.collection.objects.getRandom&count=2&limit=10&status=1&path=objects.%23.wof:id&paginated=false&shoebox_images=primary": []string{
+			"https://static.sfomuseum.org/media/1021_k_k.jpg#v1;label=o;id=102;item=102;image=1021;size=k;type=object",
+			"https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=101;item=101;image=1011;size=k;type=object",
+		},
+		"method=sfomuseum.collection.images.search&path=images.%23.id&ids=image&uri_template=https://static.sfomuseum.org/media/{id}_b.jpg&shoebox_limit=3": []string{
+			"https://static.sfomuseum.org/media/1011_b.jpg#v1;label=o;id=1011;item=0;image=1011;type=image",
+			"https://static.sfomuseum.org/media/1012_b.jpg#v1;label=o;id=1012;item=0;image=1012;type=image",
+			"https://static.sfomuseum.org/media/1021_b.jpg#v1;label=o;id=1021;item=0;image=1021;type=image",
+		},
+	}
+
+	for str_params, expected := range tests {
+
+		params, _ := url.ParseQuery(str_params)
+
+		case_q := maps.Clone(q)
+
+		for k, v := range params {
+			case_q[k] = v
+		}
+
+		b, err := NewAPIBucket(ctx, fmt.Sprintf("api://?%s", case_q.Encode()))
+
+		if err != nil {
+			t.Fatalf("Failed to create API bucket for '%s', %v", str_params, err)
+		}
+
+		uris := make([]string, 0)
+
+		for uri, err := range b.GatherPictures(ctx) {
+
+			if err != nil {
+				t.Fatalf("Failed to gather pictures for '%s', %v", str_params, err)
+			}
+
+			uris = append(uris, uri)
+		}
+
+		if !slices.Equal(uris, expected) {
+			t.Fatalf("Unexpected pictures for '%s': %v", str_params, uris)
+		}
+	}
+
+	invalid := []string{
+		"path=objects.%23.wof:id",
+		"method=sfomuseum.collection.objects.getRandom",
+		"method=sfomuseum.collection.images.search&path=images.%23.id&ids=image",
+		"method=sfomuseum.collection.images.search&path=images.%23.id&ids=video",
+		"method=sfomuseum.collection.objects.getRandom&path=objects.%23.wof:id&shoebox_sample=10",
+	}
+
+	for _, str_params := range invalid {
+
+		_, err := NewAPIBucket(ctx, fmt.Sprintf("api://?shoebox_token=TOKEN&%s", str_params))
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str_params)
+		}
+	}
+}
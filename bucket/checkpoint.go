@@ -0,0 +1,512 @@
+package bucket
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+)
+
+// checkpointIgnoreParameters are the bucket URI parameters which do not affect which items are listed or the pictures they are
+// resolved to and are excluded when deriving a checkpoint key.
+var checkpointIgnoreParameters = []string{
+	"token",
+	"checkpoint",
+	"report",
+	"workers",
+	"cache",
+	"cache_revalidate",
+	"dedupe_distance",
+	"max_attempts",
+	"backoff",
+	"max_backoff",
+	"rate_limit",
+	"connect_timeout",
+	"read_timeout",
+	"timeout",
+	"proxy",
+	"ca_bundle",
+	"user_agent",
+}
+
+// shoeboxCheckpoint records the progress of gathering the pictures for a shoebox and the picture URIs for the shoebox
+// items that have been resolved successfully.
+type shoeboxCheckpoint struct {
+	// The checkpoint key derived from the shoebox:// bucket URI the checkpoint was created for.
+	Key string `json:"key"`
+	// The seed used to randomly sample shoebox items, if they are being sampled. This is recorded so that a resumed
+	// checkpoint draws the same sample even if the seed was chosen randomly rather than defined by the ?seed= parameter.
+	Seed *uint64 `json:"seed,omitempty"`
+	// The last page of results to be processed completely.
+	Page int `json:"page"`
+	// The unique identifier of the last item to be processed.
+	ItemId int64 `json:"item_id"`
+	// The pages of results which have been processed completely, in the order they were listed. These are used to resume listing
+	// items from the last of these pages rather than the first page of results. Pages are not recorded for sampled items since all
+	// the pages of results need to be listed in order to sample them.
+	Pages []*shoeboxCheckpointPage `json:"pages,omitempty"`
+	// The Unix timestamp when the checkpoint was last updated.
+	LastModified int64 `json:"lastmodified"`
+	// The shoebox items which have been resolved successfully, keyed by their unique identifier.
+	Items map[int64]*shoeboxCheckpointItem `json:"items"`
+	mu    *sync.RWMutex
+}
+
+// shoeboxCheckpointItem is the list of picture URIs resolved for a shoebox item.
+type shoeboxCheckpointItem struct {
+	// The `lastmodified` value of the shoebox item when it was resolved.
+	LastModified int64 `json:"lastmodified"`
+	// The picture URIs resolved for the shoebox item.
+	URIs []string `json:"uris"`
+}
+
+// shoeboxCheckpointPage is a page of results which has been processed completely.
+type shoeboxCheckpointPage struct {
+	// The page number of the results.
+	Page int `json:"page"`
+	// The items derived from the page of results.
+	Items []*response.ShoeboxListItem `json:"items"`
+	// Identifiers in the page of results which could not be resolved to an item.
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// equals returns a boolean value indicating whether 'p' contains the same items, with the same `lastmodified` values, as 'cp_p'.
+func (cp_p *shoeboxCheckpointPage) equals(p *shoeboxItemsPage) bool {
+
+	if p.page != cp_p.Page {
+		return false
+	}
+
+	return slices.EqualFunc(cp_p.Items, p.items, func(a *response.ShoeboxListItem, b *response.ShoeboxListItem) bool {
+		return a.Id == b.Id && a.LastModified == b.LastModified
+	})
+}
+
+// newShoeboxCheckpoint returns a new (empty) `shoeboxCheckpoint` instance for the checkpoint key 'key'.
+func newShoeboxCheckpoint(key string) *shoeboxCheckpoint {
+
+	cp := &shoeboxCheckpoint{
+		Key:   key,
+		Items: make(map[int64]*shoeboxCheckpointItem),
+		mu:    new(sync.RWMutex),
+	}
+
+	return cp
+}
+
+// result returns a new `shoeboxItemResult` instance for shoebox item 'i' derived from 'cp' and a boolean value indicating
+// whether 'i' was present in 'cp'. Items whose `lastmodified` value has changed since they were recorded are not considered present.
+func (cp *shoeboxCheckpoint) result(i *response.ShoeboxListItem) (*shoeboxItemResult, bool) {
+
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	ci, exists := cp.Items[i.Id]
+
+	if !exists || ci.LastModified != i.LastModified {
+		return nil, false
+	}
+
+	r := &shoeboxItemResult{
+		item:    i,
+		uris:    slices.Clone(ci.URIs),
+		skipped: make([]string, 0),
+	}
+
+	return r, true
+}
+
+// update records the shoebox item associated with 'r' in 'cp' if all of its pictures were resolved successfully. Items which were
+// skipped (in whole or in part) or replaced by placeholders are removed so that they will be resolved again when gathering is resumed.
+func (cp *shoeboxCheckpoint) update(r *shoeboxItemResult) {
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.ItemId = r.item.Id
+
+	if r.err != nil || len(r.skipped) > 0 || r.placeholder != "" || len(r.uris) == 0 {
+		delete(cp.Items, r.item.Id)
+		return
+	}
+
+	cp.Items[r.item.Id] = &shoeboxCheckpointItem{
+		LastModified: r.item.LastModified,
+		URIs:         slices.Clone(r.uris),
+	}
+}
+
+// addPage records 'p' as the last page of results to be processed completely. Pages which precede (or are) the last page recorded
+// in 'cp' are ignored, since they will have been replayed from 'cp' when gathering was resumed.
+func (cp *shoeboxCheckpoint) addPage(p *shoeboxItemsPage) {
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if p.page <= cp.Page {
+		return
+	}
+
+	cp_p := &shoeboxCheckpointPage{
+		Page:       p.page,
+		Items:      slices.Clone(p.items),
+		Unresolved: slices.Clone(p.unresolved),
+	}
+
+	cp.Page = p.page
+	cp.Pages = append(cp.Pages, cp_p)
+}
+
+// items returns an iterator of batches of items derived from 'list'. If 'cp' records pages of results which have been processed
+// completely then, rather than listing all the pages again, listing starts from the last of those pages. If that page still contains
+// the same items, with the same `lastmodified` values, the preceding pages are replayed from 'cp' and listing continues from there.
+// Otherwise the items have changed since 'cp' was recorded so its pages are discarded and items are listed from the beginning.
+// Changes to the items on the preceding pages, which are not listed again, are not detected.
+func (cp *shoeboxCheckpoint) items(list shoeboxItemsLister) iter.Seq2[*shoeboxItemsPage, error] {
+
+	return func(yield func(*shoeboxItemsPage, error) bool) {
+
+		cp.mu.RLock()
+		pages := slices.Clone(cp.Pages)
+		cp.mu.RUnlock()
+
+		from_start := func() {
+
+			for p, err := range list(1, 0) {
+
+				if !yield(p, err) {
+					return
+				}
+			}
+		}
+
+		if len(pages) == 0 {
+			from_start()
+			return
+		}
+
+		last := pages[len(pages)-1]
+		count := 0
+
+		for _, cp_p := range pages[:len(pages)-1] {
+			count += len(cp_p.Items)
+		}
+
+		next, stop := iter.Pull2(list(last.Page, count))
+		defer stop()
+
+		var p *shoeboxItemsPage
+
+		for {
+
+			v, err, ok := next()
+
+			if !ok {
+				break
+			}
+
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			// Listers which can not start at a given page yield the preceding pages as well
+
+			if v.page >= last.Page {
+				p = v
+				break
+			}
+		}
+
+		if p == nil || !last.equals(p) {
+
+			stop()
+
+			slog.Warn("Items have changed since the checkpoint was recorded, listing items from the beginning", "page", last.Page)
+
+			cp.mu.Lock()
+			cp.Page = 0
+			cp.Pages = nil
+			cp.mu.Unlock()
+
+			from_start()
+			return
+		}
+
+		for _, cp_p := range pages[:len(pages)-1] {
+
+			replay := &shoeboxItemsPage{
+				page:       cp_p.Page,
+				items:      cp_p.Items,
+				unresolved: cp_p.Unresolved,
+			}
+
+			if !yield(replay, nil) {
+				return
+			}
+		}
+
+		if !yield(p, nil) {
+			return
+		}
+
+		for {
+
+			v, err, ok := next()
+
+			if !ok || !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// RemoveCheckpoint removes the checkpoint recorded by the bucket defined by 'uri', if it defines a `?checkpoint=` parameter. Checkpoints
+// are not removed by buckets once all their pictures have been gathered, since the application using the bucket may still fail to process
+// those pictures, so applications should call this method once they have done so successfully.
+func RemoveCheckpoint(ctx context.Context, uri string) error {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := shoeboxParameters(u)
+
+	if !q.Has("checkpoint") {
+		return nil
+	}
+
+	s, err := newShoeboxCheckpointStore(ctx, q.Get("checkpoint"), checkpointKey(u))
+
+	if err != nil {
+		return fmt.Errorf("Failed to create checkpoint store, %w", err)
+	}
+
+	defer s.close()
+
+	return s.remove(ctx)
+}
+
+// shoeboxCheckpointStore reads and writes a `shoeboxCheckpoint` to either a local file or a `gocloud.dev/blob.Bucket` instance.
+type shoeboxCheckpointStore struct {
+	// The checkpoint key that checkpoints read from (and written to) the store are associated with.
+	key string
+	// The path of a local file where the checkpoint is stored. Only used if 'bucket' is nil.
+	path string
+	// An optional `gocloud.dev/blob.Bucket` instance where the checkpoint is stored.
+	bucket *blob.Bucket
+}
+
+// newShoeboxCheckpointStore returns a new `shoeboxCheckpointStore` instance for the checkpoint key 'key'. If 'uri' is a URI
+// (for example "file:///usr/local/picturebook/checkpoints" or "s3://bucket") it is assumed to be a `gocloud.dev/blob.Bucket`
+// URI and the checkpoint will be stored as "shoebox-{KEY}.json" in that bucket. Otherwise 'uri' is assumed to be the path of a
+// local file. Note that the relevant `gocloud.dev/blob` driver (for example `gocloud.dev/blob/fileblob`) must be imported by your application.
+func newShoeboxCheckpointStore(ctx context.Context, uri string, key string) (*shoeboxCheckpointStore, error) {
+
+	s := &shoeboxCheckpointStore{
+		key: key,
+	}
+
+	if !strings.Contains(uri, "://") {
+		s.path = uri
+		return s, nil
+	}
+
+	b, err := blob.OpenBucket(ctx, uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open checkpoint bucket, %w", err)
+	}
+
+	s.bucket = b
+	return s, nil
+}
+
+// read returns the `shoeboxCheckpoint` instance stored in 's' or nil if it does not exist or was created for a different checkpoint key.
+func (s *shoeboxCheckpointStore) read(ctx context.Context) (*shoeboxCheckpoint, error) {
+
+	var body []byte
+	var err error
+
+	if s.bucket != nil {
+
+		body, err = s.bucket.ReadAll(ctx, s.bucketKey())
+
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return nil, nil
+		}
+
+	} else {
+
+		body, err = os.ReadFile(s.path)
+
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read checkpoint, %w", err)
+	}
+
+	cp := newShoeboxCheckpoint(s.key)
+
+	err = json.Unmarshal(body, cp)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal checkpoint, %w", err)
+	}
+
+	if cp.Key != s.key {
+		return nil, nil
+	}
+
+	if cp.Items == nil {
+		cp.Items = make(map[int64]*shoeboxCheckpointItem)
+	}
+
+	return cp, nil
+}
+
+// write stores 'cp' in 's'. Local files are replaced atomically.
+func (s *shoeboxCheckpointStore) write(ctx context.Context, cp *shoeboxCheckpoint) error {
+
+	cp.mu.Lock()
+	cp.LastModified = time.Now().Unix()
+	body, err := json.Marshal(cp)
+	cp.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("Failed to marshal checkpoint, %w", err)
+	}
+
+	if s.bucket != nil {
+
+		err = s.bucket.WriteAll(ctx, s.bucketKey(), body, nil)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write checkpoint, %w", err)
+		}
+
+		return nil
+	}
+
+	tmp_f, err := os.CreateTemp(filepath.Dir(s.path), ".checkpoint-*")
+
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary checkpoint file, %w", err)
+	}
+
+	_, err = tmp_f.Write(body)
+
+	if err != nil {
+		tmp_f.Close()
+		os.Remove(tmp_f.Name())
+		return fmt.Errorf("Failed to write checkpoint, %w", err)
+	}
+
+	err = tmp_f.Close()
+
+	if err != nil {
+		os.Remove(tmp_f.Name())
+		return fmt.Errorf("Failed to close checkpoint, %w", err)
+	}
+
+	err = os.Rename(tmp_f.Name(), s.path)
+
+	if err != nil {
+		os.Remove(tmp_f.Name())
+		return fmt.Errorf("Failed to move checkpoint in to place, %w", err)
+	}
+
+	return nil
+}
+
+// remove removes the checkpoint stored in 's', if present.
+func (s *shoeboxCheckpointStore) remove(ctx context.Context) error {
+
+	var err error
+
+	if s.bucket != nil {
+
+		err = s.bucket.Delete(ctx, s.bucketKey())
+
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return nil
+		}
+
+	} else {
+
+		err = os.Remove(s.path)
+
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to remove checkpoint, %w", err)
+	}
+
+	return nil
+}
+
+// close closes the underlying `gocloud.dev/blob.Bucket` instance for 's', if present.
+func (s *shoeboxCheckpointStore) close() error {
+
+	if s.bucket != nil {
+		return s.bucket.Close()
+	}
+
+	return nil
+}
+
+// bucketKey returns the key for the checkpoint stored in a `gocloud.dev/blob.Bucket` instance.
+func (s *shoeboxCheckpointStore) bucketKey() string {
+	return fmt.Sprintf("shoebox-%s.json", s.key)
+}
+
+// checkpointKey returns the SHA-256 hash of the scheme and host of the bucket URI 'u' and the (sorted) query parameters which
+// affect which pictures are gathered. This is used to ensure that checkpoints are only resumed by buckets created with the same URI.
+// The access token is ignored so that rotating it does not discard any progress.
+func checkpointKey(u *url.URL) string {
+
+	key_q := url.Values{}
+
+	for k, v := range u.Query() {
+
+		name, ok := shoeboxParameterName(u, k)
+
+		if ok && slices.Contains(checkpointIgnoreParameters, name) {
+			continue
+		}
+
+		key_q[k] = v
+	}
+
+	key_u := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		RawQuery: key_q.Encode(),
+	}
+
+	sum := sha256.Sum256([]byte(key_u.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,298 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestCheckpointKey(t *testing.T) {
+
+	a, _ := url.Parse("shoebox://?token=TOKEN&year=2024&workers=4&checkpoint=a.json")
+	b, _ := url.Parse("shoebox://?year=2024&token=ROTATED&checkpoint=b.json")
+
+	if checkpointKey(a) != checkpointKey(b) {
+		t.Fatalf("Expected checkpoint keys to be equal")
+	}
+
+	for _, uri := range []string{
+		"shoebox://?token=TOKEN&year=2023",
+		"collection://?token=TOKEN&year=2024",
+		"exhibition://sfo?token=TOKEN&year=2024",
+		"shoebox://?token=TOKEN&year=2024&on_error=placeholder",
+		"shoebox://?token=TOKEN&year=2024&dedupe=uri",
+	} {
+
+		c, _ := url.Parse(uri)
+
+		if checkpointKey(a) == checkpointKey(c) {
+			t.Fatalf("Expected checkpoint keys for %s to differ", uri)
+		}
+	}
+
+	// Parameters for api:// buckets which aren't prefixed are arguments to the API method
+
+	d, _ := url.Parse("api://?method=example&shoebox_token=TOKEN&shoebox_workers=4")
+	e, _ := url.Parse("api://?method=example&shoebox_token=ROTATED")
+	f, _ := url.Parse("api://?method=example&shoebox_token=TOKEN&workers=4")
+
+	if checkpointKey(d) != checkpointKey(e) {
+		t.Fatalf("Expected api:// checkpoint keys to be equal")
+	}
+
+	if checkpointKey(d) == checkpointKey(f) {
+		t.Fatalf("Expected api:// checkpoint keys with API method arguments to differ")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	resolved := make(map[string]int)
+	modified := false
+
+	get_images := methods["sfomuseum.collection.objects.getImages"]
+
+	methods["sfomuseum.collection.objects.getImages"] = func(q url.Values) (map[string]any, error) {
+		resolved[q.Get("object_id")] += 1
+		return get_images(q)
+	}
+
+	listed := make([]string, 0)
+
+	list_items := methods["sfomuseum.you.shoebox.listItems"]
+
+	methods["sfomuseum.you.shoebox.listItems"] = func(q url.Values) (map[string]any, error) {
+
+		listed = append(listed, q.Get("page"))
+
+		body, err := list_items(q)
+
+		if err != nil || !modified {
+			return body, err
+		}
+
+		// Modify the first and last items, which are on the first and last pages of results
+
+		items := body["items"].([]any)
+
+		for _, i := range []any{items[0], items[len(items)-1]} {
+			i.(map[string]any)["lastmodified"] = 1704700000
+		}
+
+		return body, nil
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	checkpoint_path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("checkpoint", checkpoint_path)
+
+	gather := func(max int) []string {
+
+		b, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+		if err != nil {
+			t.Fatalf("Failed to create shoebox bucket, %v", err)
+		}
+
+		defer b.Close()
+
+		uris := make([]string, 0)
+
+		for uri, err := range b.GatherPictures(ctx) {
+
+			if err != nil {
+				t.Fatalf("Failed to gather pictures, %v", err)
+			}
+
+			uris = append(uris, uri)
+
+			if max > 0 && len(uris) >= max {
+				break
+			}
+		}
+
+		return uris
+	}
+
+	// Stop after the first four pictures (the images for the first object and the Instagram post) have been gathered
+
+	first := gather(4)
+
+	_, err := os.Stat(checkpoint_path)
+
+	if err != nil {
+		t.Fatalf("Expected checkpoint to be written, %v", err)
+	}
+
+	// Resume, the first item should not be resolved again
+
+	clear(resolved)
+
+	resumed := gather(0)
+
+	if !slices.Equal(first, resumed[:4]) || len(resumed) != 8 {
+		t.Fatalf("Unexpected pictures after resuming: %v", resumed)
+	}
+
+	if resolved["101"] != 0 || resolved["102"] == 0 {
+		t.Fatalf("Unexpected items resolved after resuming: %v", resolved)
+	}
+
+	// The checkpoint is kept, and records the pages of results which were processed, until it is removed explicitly
+
+	cp_u, _ := url.Parse(fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+	store, err := newShoeboxCheckpointStore(ctx, checkpoint_path, checkpointKey(cp_u))
+
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint store, %v", err)
+	}
+
+	cp, err := store.read(ctx)
+
+	if err != nil || cp == nil {
+		t.Fatalf("Expected checkpoint to be kept once all pictures were gathered, %v", err)
+	}
+
+	if cp.Page != 3 || len(cp.Pages) != 3 || cp.ItemId != 6 {
+		t.Fatalf("Unexpected checkpoint progress, page %d (%d pages) item %d", cp.Page, len(cp.Pages), cp.ItemId)
+	}
+
+	// Resume again, only the last page of results should be listed
+
+	clear(resolved)
+	listed = listed[:0]
+
+	replayed := gather(0)
+
+	if !slices.Equal(resumed, replayed) {
+		t.Fatalf("Unexpected pictures after resuming from the last page: %v", replayed)
+	}
+
+	if !slices.Equal(listed, []string{"3"}) || resolved["101"] != 0 || resolved["102"] != 0 {
+		t.Fatalf("Unexpected pages listed (%v) or items resolved (%v) after resuming from the last page", listed, resolved)
+	}
+
+	// Items on the last page which have been modified since the checkpoint was recorded cause all the pages
+	// to be listed again and the modified items to be resolved again
+
+	clear(resolved)
+	listed = listed[:0]
+	modified = true
+
+	gather(0)
+
+	if !slices.Equal(listed, []string{"3", "1", "2", "3"}) {
+		t.Fatalf("Unexpected pages listed after modifying an item: %v", listed)
+	}
+
+	if resolved["101"] == 0 || resolved["102"] != 0 {
+		t.Fatalf("Expected (only) modified items to be resolved again: %v", resolved)
+	}
+
+	err = RemoveCheckpoint(ctx, fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to remove checkpoint, %v", err)
+	}
+
+	_, err = os.Stat(checkpoint_path)
+
+	if !os.IsNotExist(err) {
+		t.Fatalf("Expected checkpoint to be removed")
+	}
+}
+
+func TestCheckpointSample(t *testing.T) {
+
+	ctx := context.Background()
+
+	server := newMockAPIServer(t, mockShoeboxMethods())
+	defer server.Close()
+
+	checkpoint_path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("sample", "3")
+	q.Set("images", "primary")
+
+	gather := func(q url.Values, max int) []string {
+
+		b, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+		if err != nil {
+			t.Fatalf("Failed to create shoebox bucket, %v", err)
+		}
+
+		defer b.Close()
+
+		uris := make([]string, 0)
+
+		for uri, err := range b.GatherPictures(ctx) {
+
+			if err != nil {
+				t.Fatalf("Failed to gather pictures, %v", err)
+			}
+
+			uris = append(uris, uri)
+
+			if max > 0 && len(uris) >= max {
+				break
+			}
+		}
+
+		return uris
+	}
+
+	cp_q := maps.Clone(q)
+	cp_q.Set("checkpoint", checkpoint_path)
+
+	gather(cp_q, 1)
+
+	// The (random) seed used to sample items is recorded in the checkpoint
+
+	cp_u, _ := url.Parse(fmt.Sprintf("shoebox://?%s", cp_q.Encode()))
+
+	store, err := newShoeboxCheckpointStore(ctx, checkpoint_path, checkpointKey(cp_u))
+
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint store, %v", err)
+	}
+
+	cp, err := store.read(ctx)
+
+	if err != nil || cp == nil || cp.Seed == nil {
+		t.Fatalf("Expected checkpoint to record seed, %v, %v", cp, err)
+	}
+
+	// Resuming draws the same sample as explicitly using that seed
+
+	resumed := gather(cp_q, 0)
+
+	seed_q := maps.Clone(q)
+	seed_q.Set("seed", strconv.FormatUint(*cp.Seed, 10))
+
+	expected := gather(seed_q, 0)
+
+	if !slices.Equal(resumed, expected) {
+		t.Fatalf("Unexpected pictures after resuming: %v, expected %v", resumed, expected)
+	}
+}
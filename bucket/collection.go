@@ -0,0 +1,173 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pb_bucket "github.com/aaronland/go-picturebook/bucket"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
+)
+
+// COLLECTION_SEARCH_FACETS are the facets, in addition to a query, which may be used to search the SFO Museum Aviation Collection.
+var COLLECTION_SEARCH_FACETS = []string{
+	"medium",
+	"decade",
+	"creditline",
+	"accession_prefix",
+}
+
+// CollectionBucket implements the `aaronland/go-picturebook/bucket.Bucket` interface for use with the images for objects in the
+// SFO Museum Aviation Collection matching a search query.
+type CollectionBucket struct {
+	*ShoeboxBucket
+	search_args *url.Values
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := registerBucket(ctx, "collection", NewCollectionBucket)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewCollectionBucket returns a new `CollectionBucket` instance implementing the `aaronland/go-picturebook/bucket.Bucket` interface for use with
+// the images for objects in the SFO Museum Aviation Collection matching a search query. 'uri' is expected to take the form of:
+//
+//	collection://?{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?query={QUERY}` An optional full-text search query.
+// - `?medium={MEDIUM}` Limit objects to those with a given medium (for example "postcard").
+// - `?decade={DECADE}` Limit objects to those dated to a given decade (for example "1950s" or "1950").
+// - `?creditline={CREDITLINE}` Limit objects to those with a given credit line (for example "Gift of United Airlines").
+// - `?accession_prefix={PREFIX}` Limit objects to those whose accession numbers start with a given prefix (for example "2011.032").
+// - `?limit={N}` The maximum number of objects to include.
+// - Any of the parameters for the `shoebox://` bucket, except those used to select shoebox items, for the API endpoint, HTTP requests,
+// resolving images, error handling, reports, deduplication, checkpoints and caching. See `NewShoeboxBucket` for details. The
+// `?status=`, `?sample=` and `?seed=` parameters, which only apply to shoebox items, are reported as an error.
+//
+// At least a query or one facet must be present. The query is passed to the `sfomuseum.collection.objects.search` API method as its
+// `q` parameter and facets are passed as parameters of the same name. Since the API method ignores parameters it doesn't support, facets
+// which are not listed in its specification (as returned by the `api.spec.methods` API method) cause gathering pictures to fail. Objects
+// are included in the order they are returned by the API method and their images are resolved in the same way as objects in a shoebox
+// so the `shoebox://` caption can be used with the pictures they yield.
+func NewCollectionBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	search_args := &url.Values{}
+
+	if q.Get("query") != "" {
+		search_args.Set("q", q.Get("query"))
+	}
+
+	for _, k := range COLLECTION_SEARCH_FACETS {
+
+		v := strings.TrimSpace(q.Get(k))
+
+		if v == "" {
+			continue
+		}
+
+		if k == "decade" {
+
+			decade, err := parseDecade(v)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid ?decade= parameter, %w", err)
+			}
+
+			v = decade
+		}
+
+		search_args.Set(k, v)
+	}
+
+	if len(*search_args) == 0 {
+		return nil, fmt.Errorf("Missing ?query= parameter or one or more of the following facets: %s", strings.Join(COLLECTION_SEARCH_FACETS, ", "))
+	}
+
+	search_args.Set("method", "sfomuseum.collection.objects.search")
+
+	err = rejectShoeboxSelectParameters(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shoebox_b, err := newShoeboxBucket(ctx, u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b := &CollectionBucket{
+		ShoeboxBucket: shoebox_b,
+		search_args:   search_args,
+	}
+
+	return b, nil
+}
+
+// GatherPictures returns a new `iter.Seq2[string, error]` instance containing the URIs for the images of objects in the SFO Museum
+// Aviation Collection matching the bucket's search query. Objects which can not be resolved are handled, reported and checkpointed in
+// the same way as shoebox items. See `ShoeboxBucket.GatherPictures` for details.
+func (b *CollectionBucket) GatherPictures(ctx context.Context, uris ...string) iter.Seq2[string, error] {
+
+	// https://api.sfomuseum.org/methods/sfomuseum.collection.objects.search
+	// https://api.sfomuseum.org/methods/sfomuseum.collection.objects.getImages
+
+	return b.gather(ctx, b.searchItems)
+}
+
+// searchItems is a `shoeboxItemSource` for the objects matching the bucket's search query.
+func (b *CollectionBucket) searchItems(ctx context.Context) (map[uint8]shoebox.Resolver, shoeboxItemsLister, error) {
+
+	// The API method ignores facets it doesn't support so make sure they are listed in its specification
+
+	err := api.ValidateMethod(ctx, b.api_client, b.search_args)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid collection search, %w", err)
+	}
+
+	resolvers, type_id, err := b.objectResolvers(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resolvers, executeMethodItems(ctx, b.api_client, b.search_args, true, "objects.#.wof:id", type_id, b.items_opts.limit), nil
+}
+
+// parseDecade returns the normalized form ("{YYYY}") of 'v' which is expected to take the form of "{YYYY}" or "{YYYY}s".
+func parseDecade(v string) (string, error) {
+
+	str_decade := strings.TrimSuffix(strings.ToLower(v), "s")
+
+	decade, err := strconv.Atoi(str_decade)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse decade '%s', %w", v, err)
+	}
+
+	if decade < 0 || decade%10 != 0 {
+		return "", fmt.Errorf("Invalid decade '%s', must be a year ending in 0", v)
+	}
+
+	return strconv.Itoa(decade), nil
+}
@@ -0,0 +1,148 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseDecade(t *testing.T) {
+
+	tests := map[string]string{
+		"1950s": "1950",
+		"1950S": "1950",
+		"1960":  "1960",
+	}
+
+	for v, expected := range tests {
+
+		decade, err := parseDecade(v)
+
+		if err != nil {
+			t.Fatalf("Failed to parse decade '%s', %v", v, err)
+		}
+
+		if decade != expected {
+			t.Fatalf("Unexpected decade for '%s': %s", v, decade)
+		}
+	}
+
+	for _, v := range []string{"1955", "fifties", ""} {
+
+		_, err := parseDecade(v)
+
+		if err == nil {
+			t.Fatalf("Expected decade '%s' to fail", v)
+		}
+	}
+}
+
+func TestCollectionBucket(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	methods["sfomuseum.collection.objects.search"] = func(q url.Values) (map[string]any, error) {
+
+		if q.Get("medium") != "postcard" || q.Get("decade") != "1950" || q.Get("creditline") != "Gift of United Airlines" {
+			return nil, fmt.Errorf("Unexpected search arguments: %v", q)
+		}
+
+		return map[string]any{
+			"_results": "objects",
+			"objects": []any{
+				map[string]any{"wof:id": 102},
+				map[string]any{"wof:id": 101},
+				map[string]any{"wof:id": 103},
+			},
+		}, nil
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("medium", "postcard")
+	q.Set("decade", "1950s")
+	q.Set("creditline", "Gift of United Airlines")
+	q.Set("images", "primary")
+
+	expected := []string{
+		"https://static.sfomuseum.org/media/1021_k_k.jpg#v1;label=o;id=102;item=102;image=1021;size=k;type=object",
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=101;item=101;image=1011;size=k;type=object",
+		"shoebox://card/103.png#v1;label=card;id=103;item=103;type=object",
+	}
+
+	b, err := NewCollectionBucket(ctx, fmt.Sprintf("collection://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create collection bucket, %v", err)
+	}
+
+	uris := make([]string, 0)
+
+	for uri, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to gather pictures, %v", err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	if len(uris) != len(expected) {
+		t.Fatalf("Unexpected number of pictures: %v", uris)
+	}
+
+	for idx, uri := range uris {
+
+		if uri != expected[idx] {
+			t.Fatalf("Unexpected picture at position %d: %s", idx, uri)
+		}
+	}
+
+	// Facets which are not listed in the specification for the API method are rejected
+
+	q.Set("accession_prefix", "2011.032")
+
+	b, err = NewCollectionBucket(ctx, fmt.Sprintf("collection://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create collection bucket, %v", err)
+	}
+
+	var gather_err error
+
+	for _, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			gather_err = err
+		}
+	}
+
+	if gather_err == nil || !strings.Contains(gather_err.Error(), "accession_prefix") {
+		t.Fatalf("Expected unsupported facet to fail, %v", gather_err)
+	}
+
+	// Parameters which only apply to shoebox items are rejected
+
+	for _, k := range []string{"status", "sample"} {
+
+		_, err = NewCollectionBucket(ctx, fmt.Sprintf("collection://?token=TOKEN&medium=postcard&%s=1", k))
+
+		if err == nil {
+			t.Fatalf("Expected collection bucket with ?%s= parameter to fail", k)
+		}
+	}
+
+	_, err = NewCollectionBucket(ctx, "collection://?token=TOKEN")
+
+	if err == nil {
+		t.Fatalf("Expected collection bucket without a query or facets to fail")
+	}
+}
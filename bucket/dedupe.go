@@ -0,0 +1,187 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"strings"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/media"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/report"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
+)
+
+// DEDUPE_URL is the dedupe mode to suppress pictures whose URI (excluding the URL fragment) has already been gathered.
+const DEDUPE_URL string = "url"
+
+// DEDUPE_PHASH is the dedupe mode to suppress pictures whose URI has already been gathered or whose perceptual hash
+// is similar to a picture which has already been gathered.
+const DEDUPE_PHASH string = "phash"
+
+// DEFAULT_DEDUPE_DISTANCE is the default maximum Hamming distance between the perceptual hashes of two pictures for them to be considered duplicates.
+const DEFAULT_DEDUPE_DISTANCE int = 6
+
+// shoeboxPicture is a picture which has been gathered from a shoebox item.
+type shoeboxPicture struct {
+	item_id int64
+	uri     string
+	phash   uint64
+}
+
+// shoeboxDeduper keeps track of the pictures which have been gathered in order to identify duplicates.
+type shoeboxDeduper struct {
+	// The dedupe mode (`DEDUPE_URL` or `DEDUPE_PHASH`).
+	mode string
+	// The maximum Hamming distance between the perceptual hashes of two pictures for them to be considered duplicates.
+	distance int
+	// The pictures which have been gathered, keyed by their URI (excluding the URL fragment).
+	uris map[string]*shoeboxPicture
+	// The pictures which have been gathered and whose perceptual hash is known.
+	phashes []*shoeboxPicture
+}
+
+// newShoeboxDeduper returns a new `shoeboxDeduper` instance for 'mode' and 'distance'.
+func newShoeboxDeduper(mode string, distance int) *shoeboxDeduper {
+
+	d := &shoeboxDeduper{
+		mode:     mode,
+		distance: distance,
+		uris:     make(map[string]*shoeboxPicture),
+		phashes:  make([]*shoeboxPicture, 0),
+	}
+
+	return d
+}
+
+// check returns a `report.Duplicate` instance if the picture 'uri', derived from the shoebox item 'item_id', is a duplicate
+// of a picture which has already been gathered. Otherwise the picture is recorded and nil is returned. The perceptual hash
+// for the picture is read from 'phashes' (if present).
+func (d *shoeboxDeduper) check(item_id int64, uri string, phashes map[string]uint64) *report.Duplicate {
+
+	key := strings.Split(uri, "#")[0]
+
+	if p, exists := d.uris[key]; exists {
+
+		dup := &report.Duplicate{
+			ItemId:         item_id,
+			URI:            uri,
+			OriginalItemId: p.item_id,
+			OriginalURI:    p.uri,
+		}
+
+		return dup
+	}
+
+	pic := &shoeboxPicture{
+		item_id: item_id,
+		uri:     uri,
+	}
+
+	phash, hashed := phashes[uri]
+
+	if d.mode == DEDUPE_PHASH && hashed {
+
+		for _, p := range d.phashes {
+
+			dist := media.HammingDistance(phash, p.phash)
+
+			if dist <= d.distance {
+
+				dup := &report.Duplicate{
+					ItemId:         item_id,
+					URI:            uri,
+					OriginalItemId: p.item_id,
+					OriginalURI:    p.uri,
+					Distance:       dist,
+				}
+
+				return dup
+			}
+		}
+
+		pic.phash = phash
+		d.phashes = append(d.phashes, pic)
+	}
+
+	d.uris[key] = pic
+	return nil
+}
+
+// perceptualHashes returns the perceptual hashes for the pictures in 'r', keyed by their URI. Hashes are read from the
+// resolver which produced each picture, if it implements the `shoebox.PerceptualHashResolver` interface, and otherwise
+// computed from the picture itself. Generated pictures are not hashed.
+func (b *ShoeboxBucket) perceptualHashes(ctx context.Context, r *shoeboxItemResult) map[string]uint64 {
+
+	phashes := make(map[string]uint64)
+
+	for _, uri := range r.uris {
+
+		if shoebox.IsGeneratedKey(uri) {
+			continue
+		}
+
+		name, ok := shoebox.ResolverNameForKey(uri)
+
+		if ok {
+
+			if pr, exists := b.resolvers[name].(shoebox.PerceptualHashResolver); exists {
+
+				if phash, known := pr.PerceptualHash(uri); known {
+					phashes[uri] = phash
+					continue
+				}
+			}
+		}
+
+		phash, err := b.computePerceptualHash(ctx, uri)
+
+		if err != nil {
+			slog.Warn("Failed to derive perceptual hash for picture", "uri", uri, "error", err)
+			continue
+		}
+
+		phashes[uri] = phash
+	}
+
+	return phashes
+}
+
+// computePerceptualHash returns the perceptual hash for the picture 'uri' derived from the picture itself. If the resolver which
+// produced the picture implements the `shoebox.ThumbnailResolver` interface then the hash is derived from a smaller version of
+// the picture so that the full-size picture isn't retrieved, and decoded, while gathering pictures.
+func (b *ShoeboxBucket) computePerceptualHash(ctx context.Context, uri string) (uint64, error) {
+
+	key := strings.Split(uri, "#")[0]
+
+	name, ok := shoebox.ResolverNameForKey(uri)
+
+	if ok {
+
+		if tr, exists := b.resolvers[name].(shoebox.ThumbnailResolver); exists {
+
+			if thumb_uri, known := tr.Thumbnail(uri); known {
+				key = thumb_uri
+			}
+		}
+	}
+
+	r, err := b.NewReader(ctx, key, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.Close()
+
+	im, _, err := image.Decode(r)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to decode %s, %w", key, err)
+	}
+
+	return media.PerceptualHash(im), nil
+}
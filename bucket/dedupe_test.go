@@ -0,0 +1,123 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/report"
+)
+
+func TestShoeboxDeduper(t *testing.T) {
+
+	d := newShoeboxDeduper(DEDUPE_PHASH, 4)
+
+	phashes := map[string]uint64{
+		"https://example.com/a.jpg#o:1:101:1":  0xf0f0f0f0f0f0f0f0,
+		"https://example.com/b.jpg#ig:2:201:2": 0xf0f0f0f0f0f0f0f1,
+		"https://example.com/c.jpg#o:3:102:3":  0x0f0f0f0f0f0f0f0f,
+	}
+
+	if d.check(1, "https://example.com/a.jpg#o:1:101:1", phashes) != nil {
+		t.Fatalf("Expected first picture not to be a duplicate")
+	}
+
+	dup := d.check(2, "https://example.com/b.jpg#ig:2:201:2", phashes)
+
+	if dup == nil || dup.OriginalItemId != 1 || dup.Distance != 1 {
+		t.Fatalf("Expected similar picture to be a duplicate: %v", dup)
+	}
+
+	if d.check(3, "https://example.com/c.jpg#o:3:102:3", phashes) != nil {
+		t.Fatalf("Expected different picture not to be a duplicate")
+	}
+
+	dup = d.check(4, "https://example.com/c.jpg#o:4:103:4", phashes)
+
+	if dup == nil || dup.OriginalItemId != 3 {
+		t.Fatalf("Expected picture with the same URI to be a duplicate: %v", dup)
+	}
+
+	// Perceptual hashes are ignored when deduping by URI
+
+	d = newShoeboxDeduper(DEDUPE_URL, 4)
+
+	d.check(1, "https://example.com/a.jpg#o:1:101:1", phashes)
+
+	if d.check(2, "https://example.com/b.jpg#ig:2:201:2", phashes) != nil {
+		t.Fatalf("Expected similar picture not to be a duplicate when deduping by URI")
+	}
+}
+
+func TestDedupeURL(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	// Object 102 shares the first image of object 101
+
+	get_images := methods["sfomuseum.collection.objects.getImages"]
+
+	methods["sfomuseum.collection.objects.getImages"] = func(q url.Values) (map[string]any, error) {
+
+		if q.Get("object_id") == "102" {
+			q.Set("object_id", "101")
+			q.Del("page")
+		}
+
+		return get_images(q)
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	report_path := filepath.Join(t.TempDir(), "report.json")
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("images", "primary")
+	q.Set("dedupe", "url")
+	q.Set("report", report_path)
+
+	b, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create shoebox bucket, %v", err)
+	}
+
+	uris := make([]string, 0)
+
+	for uri, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to gather pictures, %v", err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	if slices.Contains(uris, "https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=3;item=102;created=1704355200;image=1011;size=k;type=object") || len(uris) != 5 {
+		t.Fatalf("Unexpected pictures: %v", uris)
+	}
+
+	rpt, err := report.ReadFile(report_path)
+
+	if err != nil {
+		t.Fatalf("Failed to read report, %v", err)
+	}
+
+	if len(rpt.Duplicates) != 1 || rpt.Duplicates[0].ItemId != 3 || rpt.Duplicates[0].OriginalItemId != 1 {
+		t.Fatalf("Unexpected duplicates: %v", rpt.Duplicates)
+	}
+
+	_, err = NewShoeboxBucket(ctx, "shoebox://?token=TOKEN&dedupe=md5")
+
+	if err == nil {
+		t.Fatalf("Expected invalid ?dedupe= parameter to fail")
+	}
+}
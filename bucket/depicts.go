@@ -0,0 +1,152 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pb_bucket "github.com/aaronland/go-picturebook/bucket"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
+)
+
+// RELATIONSHIP_DEPICTS is the relationship for objects which depict a Who's On First record (for example an airport or an aircraft type).
+const RELATIONSHIP_DEPICTS string = "depicts"
+
+// RELATIONSHIP_MANUFACTURER is the relationship for objects which were manufactured by a Who's On First record (for example an aircraft manufacturer).
+const RELATIONSHIP_MANUFACTURER string = "manufacturer"
+
+// RELATIONSHIP_OPERATOR is the relationship for objects which were used, or issued, by a Who's On First record (for example an airline).
+const RELATIONSHIP_OPERATOR string = "operator"
+
+// DepictsBucket implements the `aaronland/go-picturebook/bucket.Bucket` interface for use with the images for objects in the
+// SFO Museum Aviation Collection associated with a Who's On First record, for example an airline, an aircraft type or an airport.
+type DepictsBucket struct {
+	*ShoeboxBucket
+	search_args *url.Values
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := registerBucket(ctx, "depicts", NewDepictsBucket)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewDepictsBucket returns a new `DepictsBucket` instance implementing the `aaronland/go-picturebook/bucket.Bucket` interface for use with
+// the images for objects in the SFO Museum Aviation Collection associated with a Who's On First record. 'uri' is expected to take the form of:
+//
+//	depicts://?wof_id={WOF_ID}&{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?wof_id={WOF_ID}` The unique Who's On First identifier of an airline, aircraft type, airport or other place. Required.
+// - `?relationship={RELATIONSHIP}` How objects are associated with the Who's On First record. Valid options are "depicts" (objects
+// which depict the record), "manufacturer" (objects manufactured by the record) or "operator" (objects used, or issued, by the record).
+// Default is "depicts".
+// - `?limit={N}` The maximum number of objects to include.
+// - Any of the parameters for the `shoebox://` bucket, except those used to select shoebox items, for the API endpoint, HTTP requests,
+// resolving images, error handling, reports, deduplication, checkpoints and caching. See `NewShoeboxBucket` for details. The
+// `?status=`, `?sample=` and `?seed=` parameters, which only apply to shoebox items, are reported as an error.
+//
+// Objects are included in the order they are returned by the `sfomuseum.collection.objects.search` API method and their images are
+// resolved in the same way as objects in a shoebox so the `shoebox://` caption can be used with the pictures they yield. Since that method
+// ignores parameters it doesn't know about, a relationship it doesn't support (according to the `api.spec.methods` API method) is reported
+// as an error rather than silently returning every object.
+func NewDepictsBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	if !q.Has("wof_id") {
+		return nil, fmt.Errorf("Missing ?wof_id= parameter")
+	}
+
+	wof_id, err := strconv.ParseInt(q.Get("wof_id"), 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse ?wof_id= parameter, %w", err)
+	}
+
+	if wof_id <= 0 {
+		return nil, fmt.Errorf("Invalid ?wof_id= parameter, must be a positive integer")
+	}
+
+	relationship := RELATIONSHIP_DEPICTS
+
+	if q.Has("relationship") {
+
+		v := strings.ToLower(q.Get("relationship"))
+
+		switch v {
+		case RELATIONSHIP_DEPICTS, RELATIONSHIP_MANUFACTURER, RELATIONSHIP_OPERATOR:
+			relationship = v
+		default:
+			return nil, fmt.Errorf("Invalid ?relationship= parameter, must be '%s', '%s' or '%s'", RELATIONSHIP_DEPICTS, RELATIONSHIP_MANUFACTURER, RELATIONSHIP_OPERATOR)
+		}
+	}
+
+	err = rejectShoeboxSelectParameters(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shoebox_b, err := newShoeboxBucket(ctx, u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	search_args := &url.Values{}
+	search_args.Set("method", "sfomuseum.collection.objects.search")
+	search_args.Set(relationship, strconv.FormatInt(wof_id, 10))
+
+	b := &DepictsBucket{
+		ShoeboxBucket: shoebox_b,
+		search_args:   search_args,
+	}
+
+	return b, nil
+}
+
+// GatherPictures returns a new `iter.Seq2[string, error]` instance containing the URIs for the images of objects associated with the
+// bucket's Who's On First record. Objects which can not be resolved are handled, reported and checkpointed in the same way as shoebox
+// items. See `ShoeboxBucket.GatherPictures` for details.
+func (b *DepictsBucket) GatherPictures(ctx context.Context, uris ...string) iter.Seq2[string, error] {
+
+	// https://api.sfomuseum.org/methods/sfomuseum.collection.objects.search
+	// https://api.sfomuseum.org/methods/sfomuseum.collection.objects.getImages
+
+	return b.gather(ctx, b.depictsItems)
+}
+
+// depictsItems is a `shoeboxItemSource` for the objects associated with the bucket's Who's On First record.
+func (b *DepictsBucket) depictsItems(ctx context.Context) (map[uint8]shoebox.Resolver, shoeboxItemsLister, error) {
+
+	// The API method ignores parameters it doesn't support so make sure the relationship is listed in its specification
+
+	err := api.ValidateMethod(ctx, b.api_client, b.search_args)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid depicts search, %w", err)
+	}
+
+	resolvers, type_id, err := b.objectResolvers(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resolvers, executeMethodItems(ctx, b.api_client, b.search_args, true, "objects.#.wof:id", type_id, b.items_opts.limit), nil
+}
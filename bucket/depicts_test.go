@@ -0,0 +1,112 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+)
+
+func TestDepictsBucket(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	methods["sfomuseum.collection.objects.search"] = func(q url.Values) (map[string]any, error) {
+
+		if q.Get("operator") != "1159396131" || q.Has("depicts") {
+			return nil, fmt.Errorf("Unexpected search arguments: %v", q)
+		}
+
+		return map[string]any{
+			"_results": "objects",
+			"objects": []any{
+				map[string]any{"wof:id": 101},
+				map[string]any{"wof:id": 102},
+			},
+		}, nil
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("wof_id", "1159396131")
+	q.Set("relationship", "operator")
+	q.Set("images", "primary")
+
+	b, err := NewDepictsBucket(ctx, fmt.Sprintf("depicts://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create depicts bucket, %v", err)
+	}
+
+	uris := make([]string, 0)
+
+	for uri, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to gather pictures, %v", err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	expected := []string{
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=101;item=101;image=1011;size=k;type=object",
+		"https://static.sfomuseum.org/media/1021_k_k.jpg#v1;label=o;id=102;item=102;image=1021;size=k;type=object",
+	}
+
+	if !slices.Equal(uris, expected) {
+		t.Fatalf("Unexpected pictures: %v", uris)
+	}
+
+	// Relationships which are not listed in the specification for the API method are rejected
+
+	methods[api.SPEC_METHODS] = func(q url.Values) (map[string]any, error) {
+
+		specs := []any{
+			map[string]any{
+				"name":       "sfomuseum.collection.objects.search",
+				"parameters": []any{map[string]any{"name": "q"}, map[string]any{"name": "depicts"}},
+			},
+		}
+
+		return map[string]any{"methods": specs}, nil
+	}
+
+	var gather_err error
+
+	for _, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			gather_err = err
+		}
+	}
+
+	if gather_err == nil || !strings.Contains(gather_err.Error(), "operator") {
+		t.Fatalf("Expected unsupported relationship to fail, %v", gather_err)
+	}
+
+	invalid := []string{
+		"",
+		"wof_id=pan-am",
+		"wof_id=1159396131&relationship=owner",
+	}
+
+	for _, str_params := range invalid {
+
+		_, err := NewDepictsBucket(ctx, fmt.Sprintf("depicts://?token=TOKEN&%s", str_params))
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str_params)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package bucket
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"iter"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+
+	pb_bucket "github.com/aaronland/go-picturebook/bucket"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+)
+
+// ExhibitionBucket implements the `aaronland/go-picturebook/bucket.Bucket` interface for use with the images for objects on display
+// in a SFO Museum exhibition.
+type ExhibitionBucket struct {
+	*ShoeboxBucket
+	exhibition_id int64
+	slug          string
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := registerBucket(ctx, "exhibition", NewExhibitionBucket)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewExhibitionBucket returns a new `ExhibitionBucket` instance implementing the `aaronland/go-picturebook/bucket.Bucket` interface for use
+// with the images for objects on display in a SFO Museum exhibition. 'uri' is expected to take the form of:
+//
+//	exhibition://?{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?id={EXHIBITION_ID}` The unique identifier of the exhibition.
+// - `?slug={SLUG}` The short, human-readable, identifier of the exhibition used in its URL. Ignored if `?id=` is present.
+// - `?limit={N}` The maximum number of objects to include.
+// - Any of the parameters for the `shoebox://` bucket, except those used to select shoebox items, for the API endpoint, HTTP requests,
+// resolving images, error handling, reports, deduplication, checkpoints and caching. See `NewShoeboxBucket` for details. The
+// `?status=`, `?sample=` and `?seed=` parameters, which only apply to shoebox items, are reported as an error.
+//
+// Either `?id=` or `?slug=` must be present. Objects are included in gallery order and their images are resolved in the same way as
+// objects in a shoebox. The keys for those images also encode the exhibition, gallery and case each object is on display in so that
+// the `shoebox://` caption can describe where it is on display. An object on display in more than one case is included once for
+// each case. The exhibition is retrieved using the `sfomuseum.exhibitions.getInfo` and `sfomuseum.exhibitions.getObjects` API methods,
+// whose arguments are validated against the `api.spec.methods` API method, and objects are gathered in pages of 50.
+func NewExhibitionBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	var exhibition_id int64
+
+	if q.Has("id") {
+
+		v, err := strconv.ParseInt(q.Get("id"), 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?id= parameter, %w", err)
+		}
+
+		exhibition_id = v
+	}
+
+	slug := q.Get("slug")
+
+	if exhibition_id == 0 && slug == "" {
+		return nil, fmt.Errorf("Missing ?id= or ?slug= parameter")
+	}
+
+	err = rejectShoeboxSelectParameters(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shoebox_b, err := newShoeboxBucket(ctx, u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b := &ExhibitionBucket{
+		ShoeboxBucket: shoebox_b,
+		exhibition_id: exhibition_id,
+		slug:          slug,
+	}
+
+	return b, nil
+}
+
+// GatherPictures returns a new `iter.Seq2[string, error]` instance containing the URIs for the images of objects on display in the
+// bucket's exhibition, in gallery order. Objects which can not be resolved are handled, reported and checkpointed in the same way as
+// shoebox items. See `ShoeboxBucket.GatherPictures` for details.
+func (b *ExhibitionBucket) GatherPictures(ctx context.Context, uris ...string) iter.Seq2[string, error] {
+
+	// https://api.sfomuseum.org/methods/sfomuseum.exhibitions.getInfo
+	// https://api.sfomuseum.org/methods/sfomuseum.exhibitions.getObjects
+
+	return b.gather(ctx, b.exhibitionItems)
+}
+
+// exhibitionPageSize is the number of objects on display in an exhibition yielded in each batch of items.
+const exhibitionPageSize int = 50
+
+// exhibitionItems is a `shoeboxItemSource` for the objects on display in the bucket's exhibition.
+func (b *ExhibitionBucket) exhibitionItems(ctx context.Context) (map[uint8]shoebox.Resolver, shoeboxItemsLister, error) {
+
+	resolvers, type_id, err := b.objectResolvers(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exhibition_id := b.exhibition_id
+
+	if exhibition_id == 0 {
+
+		info, err := exhibitionInfo(ctx, b.api_client, b.slug)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		exhibition_id = info.Id
+	}
+
+	objects, err := exhibitionObjects(ctx, b.api_client, exhibition_id)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &exhibitionResolver{
+		Resolver:      resolvers[type_id],
+		exhibition_id: exhibition_id,
+		objects:       new(sync.Map),
+	}
+
+	resolvers[type_id] = r
+
+	items := make([]*response.ShoeboxListItem, 0)
+
+	for _, obj := range objects {
+
+		// An object may be on display in more than one case so each (object, case) entry is a separate item
+
+		i := &response.ShoeboxListItem{
+			Id:     exhibitionEntryId(obj),
+			ItemId: obj.Id,
+			TypeId: type_id,
+		}
+
+		r.objects.Store(i.Id, obj)
+		items = append(items, i)
+
+		if b.items_opts.limit > 0 && len(items) >= b.items_opts.limit {
+			break
+		}
+	}
+
+	// All the objects are retrieved up front, in order to sort them in gallery order, but they are
+	// yielded in batches so that progress can be checkpointed while their images are resolved.
+
+	list := func(start int, count int) iter.Seq2[*shoeboxItemsPage, error] {
+		return pagedItems(items, exhibitionPageSize, start)
+	}
+
+	return resolvers, list, nil
+}
+
+// pagedItems returns an iterator of batches of 'items', with 'size' items per batch, starting with the batch numbered 'start'.
+func pagedItems(items []*response.ShoeboxListItem, size int, start int) iter.Seq2[*shoeboxItemsPage, error] {
+
+	return func(yield func(*shoeboxItemsPage, error) bool) {
+
+		for offset := (start - 1) * size; offset < len(items); offset += size {
+
+			p := &shoeboxItemsPage{
+				page:  offset/size + 1,
+				items: items[offset:min(offset+size, len(items))],
+			}
+
+			if !yield(p, nil) {
+				return
+			}
+		}
+	}
+}
+
+// exhibitionEntryId returns the unique identifier for the item derived from 'obj'. This is a (positive) 63-bit FNV-1a hash of the
+// object's ID, gallery and case so that an object on display in more than one case yields a separate item for each case.
+func exhibitionEntryId(obj *response.ExhibitionObject) int64 {
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d;%s;%s", obj.Id, obj.Gallery, obj.Case)
+
+	return int64(h.Sum64() >> 1)
+}
+
+// exhibitionInfo returns the `response.ExhibitionInfo` instance for the exhibition whose slug is 'slug' using the
+// `sfomuseum.exhibitions.getInfo` API method.
+func exhibitionInfo(ctx context.Context, api_client client.Client, slug string) (*response.ExhibitionInfo, error) {
+
+	args := &url.Values{}
+	args.Set("method", "sfomuseum.exhibitions.getInfo")
+	args.Set("slug", slug)
+
+	err := api.ValidateMethod(ctx, api_client, args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve exhibition by slug, %w", err)
+	}
+
+	rsp, err := api_client.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to execute sfomuseum.exhibitions.getInfo method, %w", err)
+	}
+
+	defer rsp.Close()
+
+	var info_rsp *response.ExhibitionInfoResponse
+
+	dec := json.NewDecoder(rsp)
+	err = dec.Decode(&info_rsp)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal exhibition response, %w", err)
+	}
+
+	if info_rsp.Exhibition == nil || info_rsp.Exhibition.Id == 0 {
+		return nil, fmt.Errorf("Missing exhibition '%s'", slug)
+	}
+
+	return info_rsp.Exhibition, nil
+}
+
+// exhibitionObjects returns the list of objects on display in the exhibition whose ID is 'exhibition_id', in gallery order, using
+// the `sfomuseum.exhibitions.getObjects` API method. Objects whose position is not known are listed after all the other objects.
+func exhibitionObjects(ctx context.Context, api_client client.Client, exhibition_id int64) ([]*response.ExhibitionObject, error) {
+
+	args := &url.Values{}
+	args.Set("method", "sfomuseum.exhibitions.getObjects")
+	args.Set("exhibition_id", strconv.FormatInt(exhibition_id, 10))
+
+	err := api.ValidateMethod(ctx, api_client, args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve exhibition objects, %w", err)
+	}
+
+	objects := make([]*response.ExhibitionObject, 0)
+
+	for r, err := range client.ExecuteMethodPaginatedWithClient(ctx, api_client, http.MethodGet, args) {
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to execute sfomuseum.exhibitions.getObjects method, %w", err)
+		}
+
+		var objects_rsp *response.ExhibitionObjectsResponse
+
+		dec := json.NewDecoder(r)
+		err = dec.Decode(&objects_rsp)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal exhibition objects response, %w", err)
+		}
+
+		objects = append(objects, objects_rsp.Objects...)
+	}
+
+	// Objects are sorted once all the pages of results have been retrieved since their gallery order
+	// is not guaranteed to match the order in which they are returned by the API.
+
+	slices.SortStableFunc(objects, func(a *response.ExhibitionObject, b *response.ExhibitionObject) int {
+
+		switch {
+		case a.Position == b.Position:
+			return 0
+		case a.Position == 0:
+			return 1
+		case b.Position == 0:
+			return -1
+		default:
+			return cmp.Compare(a.Position, b.Position)
+		}
+	})
+
+	return objects, nil
+}
+
+// exhibitionResolver wraps a `shoebox.Resolver` for objects adding the exhibition, gallery and case an object is on display
+// in to the keys for its images.
+type exhibitionResolver struct {
+	shoebox.Resolver
+	// exhibition_id is the unique identifier of the exhibition the objects are on display in.
+	exhibition_id int64
+	// objects is a map of `response.ExhibitionObject` instances keyed by item ID (see `exhibitionEntryId`).
+	objects *sync.Map
+}
+
+// Resolve returns an iterator of image URIs for the object associated with item 'i' with the exhibition context for that object
+// added to each key.
+func (r *exhibitionResolver) Resolve(ctx context.Context, i *response.ShoeboxListItem) iter.Seq2[string, error] {
+
+	return func(yield func(string, error) bool) {
+
+		var obj *response.ExhibitionObject
+
+		if v, exists := r.objects.Load(i.Id); exists {
+			obj = v.(*response.ExhibitionObject)
+		}
+
+		for uri, err := range r.Resolver.Resolve(ctx, i) {
+
+			if err == nil {
+
+				k, parse_err := keys.Parse(uri)
+
+				if parse_err != nil {
+					err = fmt.Errorf("Failed to parse key for object %d, %w", i.ItemId, parse_err)
+				} else {
+
+					k.Exhibition = r.exhibition_id
+
+					if obj != nil {
+						k.Gallery = obj.Gallery
+						k.Case = obj.Case
+					}
+
+					uri = keys.Encode(k)
+				}
+			}
+
+			if !yield(uri, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+func TestExhibitionBucket(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	methods["sfomuseum.exhibitions.getInfo"] = func(q url.Values) (map[string]any, error) {
+
+		if q.Get("slug") != "fly-me-to-the-moon" {
+			return nil, fmt.Errorf("Unexpected arguments: %v", q)
+		}
+
+		return map[string]any{
+			"exhibition": map[string]any{
+				"wof:id": 1729,
+				"title":  "Fly Me to the Moon",
+				"slug":   "fly-me-to-the-moon",
+			},
+		}, nil
+	}
+
+	methods["sfomuseum.exhibitions.getObjects"] = func(q url.Values) (map[string]any, error) {
+
+		if q.Get("exhibition_id") != "1729" {
+			return nil, fmt.Errorf("Unexpected arguments: %v", q)
+		}
+
+		return map[string]any{
+			"_results": "objects",
+			"objects": []any{
+				map[string]any{"wof:id": 103, "gallery": "Terminal 2", "case": "Case 2", "position": 3},
+				map[string]any{"wof:id": 102, "gallery": "Terminal 2", "case": "Case 1", "position": 2},
+				map[string]any{"wof:id": 101, "gallery": "Terminal 2", "case": "Case 1", "position": 1},
+				map[string]any{"wof:id": 101, "gallery": "Terminal 3", "case": "Case 9", "position": 4},
+			},
+		}, nil
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("slug", "fly-me-to-the-moon")
+	q.Set("images", "primary")
+	q.Set("cards", "false")
+
+	b, err := NewExhibitionBucket(ctx, fmt.Sprintf("exhibition://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create exhibition bucket, %v", err)
+	}
+
+	uris := make([]string, 0)
+
+	for uri, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to gather pictures, %v", err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	// An object on display in more than one case yields pictures for each case
+
+	entry_id := func(id int64, gallery string, case_name string) int64 {
+		return exhibitionEntryId(&response.ExhibitionObject{Id: id, Gallery: gallery, Case: case_name})
+	}
+
+	expected := []string{
+		fmt.Sprintf("https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=%d;item=101;image=1011;size=k;type=object;exhibition=1729;gallery=Terminal+2;case=Case+1", entry_id(101, "Terminal 2", "Case 1")),
+		fmt.Sprintf("https://static.sfomuseum.org/media/1021_k_k.jpg#v1;label=o;id=%d;item=102;image=1021;size=k;type=object;exhibition=1729;gallery=Terminal+2;case=Case+1", entry_id(102, "Terminal 2", "Case 1")),
+		fmt.Sprintf("https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=%d;item=101;image=1011;size=k;type=object;exhibition=1729;gallery=Terminal+3;case=Case+9", entry_id(101, "Terminal 3", "Case 9")),
+	}
+
+	if !slices.Equal(uris, expected) {
+		t.Fatalf("Unexpected pictures: %v", uris)
+	}
+
+	// API methods which are not listed by the api.spec.methods API method are rejected
+
+	delete(methods, "sfomuseum.exhibitions.getObjects")
+
+	var gather_err error
+
+	for _, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			gather_err = err
+		}
+	}
+
+	if gather_err == nil {
+		t.Fatalf("Expected exhibition without a getObjects method to fail")
+	}
+
+	_, err = NewExhibitionBucket(ctx, "exhibition://?token=TOKEN")
+
+	if err == nil {
+		t.Fatalf("Expected exhibition bucket without an ID or slug to fail")
+	}
+}
+
+func TestPagedItems(t *testing.T) {
+
+	items := make([]*response.ShoeboxListItem, 5)
+
+	for idx := range items {
+		items[idx] = &response.ShoeboxListItem{Id: int64(idx + 1)}
+	}
+
+	pages := make([]int, 0)
+	ids := make([]int64, 0)
+
+	for p := range pagedItems(items, 2, 2) {
+
+		pages = append(pages, p.page)
+
+		for _, i := range p.items {
+			ids = append(ids, i.Id)
+		}
+	}
+
+	if !slices.Equal(pages, []int{2, 3}) || !slices.Equal(ids, []int64{3, 4, 5}) {
+		t.Fatalf("Unexpected pages (%v) or items (%v)", pages, ids)
+	}
+}
@@ -0,0 +1,141 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pb_bucket "github.com/aaronland/go-picturebook/bucket"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
+)
+
+// InstagramBucket implements the `aaronland/go-picturebook/bucket.Bucket` interface for use with the images for posts in the
+// SFO Museum (Mills Field) Instagram archive.
+type InstagramBucket struct {
+	*ShoeboxBucket
+	hashtag string
+	user    string
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := registerBucket(ctx, "instagram", NewInstagramBucket)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewInstagramBucket returns a new `InstagramBucket` instance implementing the `aaronland/go-picturebook/bucket.Bucket` interface for use
+// with the images for posts in the SFO Museum (Mills Field) Instagram archive. 'uri' is expected to take the form of:
+//
+//	instagram://?{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?year={YYYY}` Limit posts to those posted during a specific year.
+// - `?date={DATE_RANGE}` Limit posts to those posted during a date or date range. See `dates.ParseRange` for details.
+// - `?min_date={DATE}` Limit posts to those posted on or after the start of this date. See `dates.ParseRange` for details.
+// - `?max_date={DATE}` Limit posts to those posted on or before the end of this date. See `dates.ParseRange` for details.
+// - `?hashtag={HASHTAG}` Limit posts to those whose caption contains this hashtag (with or without a leading "#").
+// - `?user={USERNAME}` Limit posts to those whose caption mentions this user (with or without a leading "@").
+// - `?sort={ORDER}` The order in which posts are listed. Valid options are "asc" (oldest first) or "desc" (newest first). Default is "asc".
+// - `?limit={N}` The maximum number of posts to include.
+// - Any of the parameters for the `shoebox://` bucket, except those used to select shoebox items, for the API endpoint, HTTP requests,
+// error handling, reports, deduplication, checkpoints and caching. See `NewShoeboxBucket` for details. The
+// `?status=`, `?sample=` and `?seed=` parameters, which only apply to shoebox items, are reported as an error.
+//
+// Posts are listed using the `sfomuseum.millsfield.instagram.search` API method and their images are resolved, and captioned, in the
+// same way as Instagram posts in a shoebox. Since that method ignores parameters it doesn't know about, filters it doesn't support
+// (according to the `api.spec.methods` API method) are reported as an error rather than silently returning every post.
+func NewInstagramBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	err = rejectShoeboxSelectParameters(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shoebox_b, err := newShoeboxBucket(ctx, u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b := &InstagramBucket{
+		ShoeboxBucket: shoebox_b,
+		hashtag:       strings.TrimPrefix(strings.TrimSpace(q.Get("hashtag")), "#"),
+		user:          strings.TrimPrefix(strings.TrimSpace(q.Get("user")), "@"),
+	}
+
+	return b, nil
+}
+
+// GatherPictures returns a new `iter.Seq2[string, error]` instance containing the URIs for the images of the posts in the Instagram
+// archive matching the bucket's criteria. Posts which can not be resolved are handled, reported and checkpointed in the same way as
+// shoebox items. See `ShoeboxBucket.GatherPictures` for details.
+func (b *InstagramBucket) GatherPictures(ctx context.Context, uris ...string) iter.Seq2[string, error] {
+
+	// https://api.sfomuseum.org/methods/sfomuseum.millsfield.instagram.search
+	// https://api.sfomuseum.org/methods/sfomuseum.millsfield.instagram.getInfo
+
+	return b.gather(ctx, b.instagramItems)
+}
+
+// instagramItems is a `shoeboxItemSource` for the posts in the Instagram archive matching the bucket's criteria.
+func (b *InstagramBucket) instagramItems(ctx context.Context) (map[uint8]shoebox.Resolver, shoeboxItemsLister, error) {
+
+	resolvers, types_map, err := b.typeResolvers(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type_id, exists := types_map["instagram"]
+
+	if !exists {
+		return nil, nil, fmt.Errorf("Failed to derive shoebox type ID for Instagram posts")
+	}
+
+	search_args := &url.Values{}
+	search_args.Set("method", "sfomuseum.millsfield.instagram.search")
+	search_args.Set("sort", strings.ToUpper(b.items_opts.sort))
+
+	if b.min_date > 0 {
+		search_args.Set("min_date", strconv.FormatInt(b.min_date, 10))
+	}
+
+	if b.max_date > 0 {
+		search_args.Set("max_date", strconv.FormatInt(b.max_date, 10))
+	}
+
+	if b.hashtag != "" {
+		search_args.Set("hashtag", b.hashtag)
+	}
+
+	if b.user != "" {
+		search_args.Set("user", b.user)
+	}
+
+	// The API method ignores parameters it doesn't support so make sure they are listed in its specification
+
+	err = api.ValidateMethod(ctx, b.api_client, search_args)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid Instagram search, %w", err)
+	}
+
+	return resolvers, executeMethodItems(ctx, b.api_client, search_args, true, "posts.#.wof:id", type_id, b.items_opts.limit), nil
+}
@@ -0,0 +1,85 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInstagramBucket(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	methods["sfomuseum.millsfield.instagram.search"] = func(q url.Values) (map[string]any, error) {
+
+		// 2018 in America/Los_Angeles
+
+		if q.Get("min_date") != "1514793600" || q.Get("max_date") != "1546329599" || q.Get("hashtag") != "sfomuseum" || q.Get("user") != "flysfo" || q.Get("sort") != "ASC" {
+			return nil, fmt.Errorf("Unexpected arguments: %v", q)
+		}
+
+		return map[string]any{
+			"_results": "posts",
+			"posts": []any{
+				map[string]any{"wof:id": 2010},
+			},
+		}, nil
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("year", "2018")
+	q.Set("hashtag", "#sfomuseum")
+	q.Set("user", "@flysfo")
+
+	b, err := NewInstagramBucket(ctx, fmt.Sprintf("instagram://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create Instagram bucket, %v", err)
+	}
+
+	uris := make([]string, 0)
+
+	for uri, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to gather pictures, %v", err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	expected := []string{
+		"https://static.sfomuseum.org/media/2010_ig_b.jpg#v1;label=ig;id=2010;item=2010;type=instagram",
+	}
+
+	if !slices.Equal(uris, expected) {
+		t.Fatalf("Unexpected pictures: %v", uris)
+	}
+
+	// API methods which are not listed by the api.spec.methods API method are rejected
+
+	delete(methods, "sfomuseum.millsfield.instagram.search")
+
+	var gather_err error
+
+	for _, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			gather_err = err
+		}
+	}
+
+	if gather_err == nil || !strings.Contains(gather_err.Error(), "not available") {
+		t.Fatalf("Expected Instagram search without a search method to fail, %v", gather_err)
+	}
+}
@@ -0,0 +1,317 @@
+package bucket
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+)
+
+// SORT_ASC is the sort order for listing shoebox items from oldest to newest.
+const SORT_ASC string = "asc"
+
+// SORT_DESC is the sort order for listing shoebox items from newest to oldest.
+const SORT_DESC string = "desc"
+
+// shoeboxItemsOptions defines the options for selecting which shoebox items are included in a picturebook.
+type shoeboxItemsOptions struct {
+	// The sort order (`SORT_ASC` or `SORT_DESC`) of shoebox items.
+	sort string
+	// Zero or more shoebox item status codes to include. If empty all items are included.
+	status []uint8
+	// The maximum number of shoebox items to include. If zero there is no limit.
+	limit int
+	// The number of shoebox items to randomly sample. If zero items are not sampled.
+	sample int
+	// The seed used to randomly sample shoebox items.
+	seed uint64
+}
+
+// shoeboxSelectParameters are the `shoebox://` bucket parameters for filtering shoebox items by status and sampling them. They
+// are only supported by buckets which list the items in a shoebox.
+var shoeboxSelectParameters = []string{
+	"status",
+	"sample",
+	"seed",
+}
+
+// rejectShoeboxSelectParameters returns an error if any of the `shoeboxSelectParameters` are present in 'q'. It is used by buckets
+// which don't list the items in a shoebox so that these parameters are reported rather than silently ignored.
+func rejectShoeboxSelectParameters(q url.Values) error {
+
+	for _, k := range shoeboxSelectParameters {
+
+		if q.Has(k) {
+			return fmt.Errorf("Unsupported ?%s= parameter, only shoebox items can be filtered by status or sampled", k)
+		}
+	}
+
+	return nil
+}
+
+// newShoeboxItemsOptions returns a new `shoeboxItemsOptions` instance derived from the `?sort=`, `?status=`,
+// `?limit=`, `?sample=` and `?seed=` parameters in 'q'.
+func newShoeboxItemsOptions(q url.Values) (*shoeboxItemsOptions, error) {
+
+	opts := &shoeboxItemsOptions{
+		sort:   SORT_ASC,
+		status: make([]uint8, 0),
+	}
+
+	if q.Has("sort") {
+
+		v := strings.ToLower(q.Get("sort"))
+
+		switch v {
+		case SORT_ASC, SORT_DESC:
+			opts.sort = v
+		default:
+			return nil, fmt.Errorf("Invalid ?sort= parameter, must be '%s' or '%s'", SORT_ASC, SORT_DESC)
+		}
+	}
+
+	for _, v := range q["status"] {
+
+		for _, str_status := range strings.Split(v, ",") {
+
+			str_status = strings.TrimSpace(str_status)
+
+			if str_status == "" {
+				continue
+			}
+
+			status, err := strconv.ParseUint(str_status, 10, 8)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse ?status= parameter '%s', %w", str_status, err)
+			}
+
+			opts.status = append(opts.status, uint8(status))
+		}
+	}
+
+	if q.Has("limit") {
+
+		v, err := strconv.Atoi(q.Get("limit"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?limit= parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid ?limit= parameter, must be greater than zero")
+		}
+
+		opts.limit = v
+	}
+
+	if q.Has("sample") {
+
+		v, err := strconv.Atoi(q.Get("sample"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?sample= parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid ?sample= parameter, must be greater than zero")
+		}
+
+		opts.sample = v
+		opts.seed = rand.Uint64()
+	}
+
+	if q.Has("seed") {
+
+		if !q.Has("sample") {
+			return nil, fmt.Errorf("Invalid ?seed= parameter, requires ?sample= parameter")
+		}
+
+		v, err := strconv.ParseUint(q.Get("seed"), 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?seed= parameter, %w", err)
+		}
+
+		opts.seed = v
+	}
+
+	return opts, nil
+}
+
+// include returns a boolean value indicating whether shoebox item 'i' has one of the status codes defined in 'opts'.
+func (opts *shoeboxItemsOptions) include(i *response.ShoeboxListItem) bool {
+
+	if len(opts.status) == 0 {
+		return true
+	}
+
+	return slices.Contains(opts.status, i.Status)
+}
+
+// shoeboxItemsPage is a batch of shoebox items derived from a page of results returned by the `sfomuseum.you.shoebox.listItems` API method.
+type shoeboxItemsPage struct {
+	// The page number of the results the items were derived from. For sampled items this is the last page of results.
+	page int
+	// The shoebox items derived from the page of results.
+	items []*response.ShoeboxListItem
+	// Identifiers (for example accession numbers) in the page of results which could not be resolved to an item.
+	unresolved []string
+}
+
+// listItems returns a `shoeboxItemsLister` for batches of shoebox items, one batch per page of results returned by the
+// `sfomuseum.you.shoebox.listItems` API method, using the `args` as the base set of arguments. Items are filtered,
+// limited and sampled according to 'opts'. Unless items are being sampled no further pages of results are requested
+// once the limit defined in 'opts' is reached. Sampled items are always listed from the first page of results.
+func listItems(ctx context.Context, api_client client.Client, args *url.Values, opts *shoeboxItemsOptions) shoeboxItemsLister {
+
+	return func(start int, count int) iter.Seq2[*shoeboxItemsPage, error] {
+
+		return func(yield func(*shoeboxItemsPage, error) bool) {
+
+			list_args := &url.Values{}
+
+			for k, v := range *args {
+				(*list_args)[k] = v
+			}
+
+			list_args.Set("method", "sfomuseum.you.shoebox.listItems")
+			list_args.Set("sort", strings.ToUpper(opts.sort))
+
+			// Sampled items are collected from all the pages of results before being yielded
+			sampled := make([]*sampledItem, 0)
+			seen := 0
+
+			// The number of the last page of results and the number of items included so far
+			page := start - 1
+			included := count
+
+			if opts.sample > 0 {
+				page = 0
+				included = 0
+			}
+
+			if page > 0 {
+				list_args.Set("page", strconv.Itoa(page+1))
+			}
+
+			rnd := rand.New(rand.NewPCG(opts.seed, opts.seed))
+
+			for list_r, err := range client.ExecuteMethodPaginatedWithClient(ctx, api_client, http.MethodGet, list_args) {
+
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+
+				page += 1
+
+				var items_rsp *response.ShoeboxListItemsResponse
+
+				dec := json.NewDecoder(list_r)
+				err = dec.Decode(&items_rsp)
+
+				if err != nil {
+					yield(nil, fmt.Errorf("Failed to unmarshal list items response, %w", err))
+					return
+				}
+
+				items := make([]*response.ShoeboxListItem, 0)
+
+				for _, i := range items_rsp.Items {
+
+					if !opts.include(i) {
+						continue
+					}
+
+					if opts.sample > 0 {
+						sampled = sampleItem(rnd, sampled, opts.sample, seen, i)
+						seen += 1
+						continue
+					}
+
+					items = append(items, i)
+					included += 1
+
+					if opts.limit > 0 && included >= opts.limit {
+						break
+					}
+				}
+
+				if opts.sample > 0 {
+					continue
+				}
+
+				if len(items) > 0 && !yield(&shoeboxItemsPage{page: page, items: items}, nil) {
+					return
+				}
+
+				if opts.limit > 0 && included >= opts.limit {
+					return
+				}
+			}
+
+			if opts.sample == 0 {
+				return
+			}
+
+			// Restore the order in which sampled items were listed
+
+			slices.SortFunc(sampled, func(a *sampledItem, b *sampledItem) int {
+				return cmp.Compare(a.offset, b.offset)
+			})
+
+			if opts.limit > 0 && len(sampled) > opts.limit {
+				sampled = sampled[:opts.limit]
+			}
+
+			items := make([]*response.ShoeboxListItem, len(sampled))
+
+			for idx, si := range sampled {
+				items[idx] = si.item
+			}
+
+			if len(items) > 0 {
+				yield(&shoeboxItemsPage{page: page, items: items}, nil)
+			}
+		}
+	}
+}
+
+// sampledItem is a shoebox item and its (zero-indexed) position in the list of all the items being sampled.
+type sampledItem struct {
+	offset int
+	item   *response.ShoeboxListItem
+}
+
+// sampleItem adds 'i', the item at (zero-indexed) position 'offset' in a list of items, to 'sampled' using reservoir
+// sampling to maintain a uniformly random sample of at most 'n' items.
+func sampleItem(rnd *rand.Rand, sampled []*sampledItem, n int, offset int, i *response.ShoeboxListItem) []*sampledItem {
+
+	si := &sampledItem{
+		offset: offset,
+		item:   i,
+	}
+
+	if len(sampled) < n {
+		return append(sampled, si)
+	}
+
+	j := rnd.IntN(offset + 1)
+
+	if j < n {
+		sampled[j] = si
+	}
+
+	return sampled
+}
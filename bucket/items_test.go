@@ -0,0 +1,197 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+func TestShoeboxItemsOptions(t *testing.T) {
+
+	q, _ := url.ParseQuery("sort=DESC&status=1,2&status=4&limit=25&sample=10&seed=42")
+
+	opts, err := newShoeboxItemsOptions(q)
+
+	if err != nil {
+		t.Fatalf("Failed to derive options, %v", err)
+	}
+
+	if opts.sort != SORT_DESC {
+		t.Fatalf("Unexpected sort: %s", opts.sort)
+	}
+
+	if !slices.Equal(opts.status, []uint8{1, 2, 4}) {
+		t.Fatalf("Unexpected status: %v", opts.status)
+	}
+
+	if opts.limit != 25 || opts.sample != 10 || opts.seed != 42 {
+		t.Fatalf("Unexpected options: %v", opts)
+	}
+
+	invalid := []string{
+		"sort=sideways",
+		"status=archived",
+		"status=256",
+		"limit=0",
+		"sample=-1",
+		"seed=42",
+	}
+
+	for _, str_q := range invalid {
+
+		q, _ := url.ParseQuery(str_q)
+
+		_, err := newShoeboxItemsOptions(q)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str_q)
+		}
+	}
+}
+
+func TestSampleItem(t *testing.T) {
+
+	sample := func(seed uint64) []int64 {
+
+		rnd := rand.New(rand.NewPCG(seed, seed))
+		sampled := make([]*sampledItem, 0)
+
+		for idx := 0; idx < 100; idx++ {
+			i := &response.ShoeboxListItem{Id: int64(idx)}
+			sampled = sampleItem(rnd, sampled, 10, idx, i)
+		}
+
+		ids := make([]int64, len(sampled))
+
+		for idx, si := range sampled {
+			ids[idx] = si.item.Id
+		}
+
+		slices.Sort(ids)
+		return ids
+	}
+
+	a := sample(42)
+	b := sample(42)
+
+	if len(a) != 10 {
+		t.Fatalf("Unexpected sample size: %d", len(a))
+	}
+
+	if !slices.Equal(a, b) {
+		t.Fatalf("Expected samples with the same seed to be equal: %v, %v", a, b)
+	}
+
+	if slices.Equal(a, sample(43)) {
+		t.Fatalf("Expected samples with different seeds to differ: %v", a)
+	}
+}
+
+func TestListItems(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	pages := 0
+	list_items := methods["sfomuseum.you.shoebox.listItems"]
+
+	methods["sfomuseum.you.shoebox.listItems"] = func(q url.Values) (map[string]any, error) {
+		pages += 1
+		return list_items(q)
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	tests := map[string][]int64{
+		"":                        []int64{1, 2, 3, 4, 5, 6},
+		"sort=desc":               []int64{6, 5, 4, 3, 2, 1},
+		"status=1":                []int64{1, 2, 3, 4, 6},
+		"limit=3":                 []int64{1, 2, 3},
+		"sort=desc&limit=2":       []int64{6, 5},
+		"sample=6&seed=1":         []int64{1, 2, 3, 4, 5, 6},
+		"sample=6&seed=1&limit=2": []int64{1, 2},
+	}
+
+	expected_pages := map[string]int{
+		"limit=3":           2,
+		"sort=desc&limit=2": 1,
+	}
+
+	for str_q, expected := range tests {
+
+		q, _ := url.ParseQuery(str_q)
+		q.Set("token", "TOKEN")
+		q.Set("api", server.URL)
+
+		b, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+		if err != nil {
+			t.Fatalf("Failed to create shoebox bucket for '%s', %v", str_q, err)
+		}
+
+		pages = 0
+		ids := make([]int64, 0)
+
+		for p, err := range listItems(ctx, b.(*ShoeboxBucket).api_client, &url.Values{}, b.(*ShoeboxBucket).items_opts)(1, 0) {
+
+			if err != nil {
+				t.Fatalf("Failed to list items for '%s', %v", str_q, err)
+			}
+
+			for _, i := range p.items {
+				ids = append(ids, i.Id)
+			}
+		}
+
+		if !slices.Equal(ids, expected) {
+			t.Fatalf("Unexpected items for '%s': %v", str_q, ids)
+		}
+
+		v, exists := expected_pages[str_q]
+
+		if exists && pages != v {
+			t.Fatalf("Unexpected number of pages requested for '%s': %d", str_q, pages)
+		}
+	}
+
+	// Sampling is reproducible
+
+	sample := func() []int64 {
+
+		q := url.Values{}
+		q.Set("token", "TOKEN")
+		q.Set("api", server.URL)
+		q.Set("sample", "3")
+		q.Set("seed", "1234")
+
+		b, _ := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+		ids := make([]int64, 0)
+
+		for p := range listItems(ctx, b.(*ShoeboxBucket).api_client, &url.Values{}, b.(*ShoeboxBucket).items_opts)(1, 0) {
+
+			for _, i := range p.items {
+				ids = append(ids, i.Id)
+			}
+		}
+
+		return ids
+	}
+
+	a := sample()
+
+	if len(a) != 3 || !slices.IsSorted(a) {
+		t.Fatalf("Unexpected sample: %v", a)
+	}
+
+	if !slices.Equal(a, sample()) {
+		t.Fatalf("Expected samples to be equal")
+	}
+}
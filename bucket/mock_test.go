@@ -0,0 +1,224 @@
+package bucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+)
+
+// mockMethodFunc returns the (unpaginated) response body for a mock API method.
+type mockMethodFunc func(url.Values) (map[string]any, error)
+
+// mockMethodParameters are the parameters, keyed by API method name, listed for the methods returned by the mock
+// `api.spec.methods` API method.
+var mockMethodParameters = map[string][]string{
+	"sfomuseum.collection.objects.search":   []string{"q", "medium", "decade", "creditline", "depicts", "manufacturer", "operator"},
+	"sfomuseum.exhibitions.getInfo":         []string{"exhibition_id", "slug"},
+	"sfomuseum.exhibitions.getObjects":      []string{"exhibition_id"},
+	"sfomuseum.millsfield.instagram.search": []string{"sort", "min_date", "max_date", "hashtag", "user"},
+}
+
+// newMockAPIServer returns a new `httptest.Server` instance implementing a minimal version of the SFO Museum API
+// for the methods in 'methods'. Any property containing a list of results, named by the "_results" key, is paginated
+// 2 results per page. Unless 'methods' defines it the `api.spec.methods` API method returns the methods in 'methods'
+// and their parameters as defined by `mockMethodParameters`.
+func newMockAPIServer(t *testing.T, methods map[string]mockMethodFunc) *httptest.Server {
+
+	spec_methods := func(q url.Values) (map[string]any, error) {
+
+		specs := make([]any, 0)
+
+		for _, name := range slices.Sorted(maps.Keys(methods)) {
+
+			params := make([]any, 0)
+
+			for _, p := range mockMethodParameters[name] {
+				params = append(params, map[string]any{"name": p})
+			}
+
+			specs = append(specs, map[string]any{"name": name, "request_method": "GET", "parameters": params})
+		}
+
+		return map[string]any{"methods": specs}, nil
+	}
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		q := req.URL.Query()
+		method := q.Get("method")
+
+		fn, exists := methods[method]
+
+		if !exists && method == api.SPEC_METHODS {
+			fn, exists = spec_methods, true
+		}
+
+		if !exists {
+			http.Error(rsp, fmt.Sprintf("Unsupported method '%s'", method), http.StatusNotFound)
+			return
+		}
+
+		body, err := fn(q)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		results_key, paginated := body["_results"].(string)
+
+		if paginated {
+
+			delete(body, "_results")
+
+			results := body[results_key].([]any)
+
+			per_page := 2
+			page := 1
+
+			if q.Has("page") {
+				page, _ = strconv.Atoi(q.Get("page"))
+			}
+
+			pages := (len(results) + per_page - 1) / per_page
+
+			if pages == 0 {
+				pages = 1
+			}
+
+			start := (page - 1) * per_page
+			end := start + per_page
+
+			if start > len(results) {
+				start = len(results)
+			}
+
+			if end > len(results) {
+				end = len(results)
+			}
+
+			body[results_key] = results[start:end]
+			body["page"] = page
+			body["pages"] = pages
+			body["per_page"] = per_page
+			body["total"] = len(results)
+		}
+
+		body["stat"] = "ok"
+
+		rsp.Header().Set("Content-Type", "application/json")
+
+		enc := json.NewEncoder(rsp)
+		enc.Encode(body)
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(handler))
+
+	// API clients perform requests using http.DefaultTransport so install a transport for the mock server, which
+	// skips TLS verification and retries quickly, for the duration of the test
+
+	transport_q := url.Values{}
+	transport_q.Set("api", server.URL)
+	transport_q.Set("insecure", "true")
+	transport_q.Set("backoff", "1ms")
+
+	restore, err := api.InstallTransportWithQuery(transport_q)
+
+	if err != nil {
+		t.Fatalf("Failed to install API transport, %v", err)
+	}
+
+	t.Cleanup(restore)
+
+	return server
+}
+
+// mockShoeboxMethods returns the `mockMethodFunc` functions for a shoebox containing two objects
+// (the first with three images), an Instagram post, a flight and a gallery (which are not supported, the
+// latter having a status of 2) and an object without any images. Items are listed in reverse order if the "sort" argument is "DESC".
+func mockShoeboxMethods() map[string]mockMethodFunc {
+
+	image := func(id int64) map[string]any {
+		return map[string]any{
+			"wof:id":             id,
+			"media:uri_template": fmt.Sprintf("https://static.sfomuseum.org/media/%d_{secret}_{label}.{extension}", id),
+			"media:properties": map[string]any{
+				"sizes": map[string]any{
+					"k": map[string]any{"secret": "k", "extension": "jpg", "width": 2048, "height": 1536},
+					"c": map[string]any{"secret": "c", "extension": "jpg", "width": 800, "height": 600},
+				},
+			},
+		}
+	}
+
+	images := map[string][]any{
+		"101": []any{image(1011), image(1012), image(1013)},
+		"102": []any{image(1021)},
+		"103": []any{},
+	}
+
+	return map[string]mockMethodFunc{
+		"sfomuseum.you.shoebox.typesMap": func(q url.Values) (map[string]any, error) {
+			return map[string]any{
+				"types": map[string]any{"object": 1, "instagram": 2, "flight": 3, "gallery": 4},
+			}, nil
+		},
+		"sfomuseum.you.shoebox.listItems": func(q url.Values) (map[string]any, error) {
+
+			items := []any{
+				map[string]any{"id": 1, "item_id": 101, "type_id": 1, "status": 1, "created": 1704182400, "lastmodified": 1704182400},
+				map[string]any{"id": 2, "item_id": 201, "type_id": 2, "status": 1, "created": 1704268800, "lastmodified": 1704268800},
+				map[string]any{"id": 3, "item_id": 102, "type_id": 1, "status": 1, "created": 1704355200, "lastmodified": 1704355200},
+				map[string]any{"id": 4, "item_id": 301, "type_id": 3, "status": 1, "created": 1704441600, "lastmodified": 1704441600},
+				map[string]any{"id": 5, "item_id": 401, "type_id": 4, "status": 2, "created": 1704528000, "lastmodified": 1704528000},
+				map[string]any{"id": 6, "item_id": 103, "type_id": 1, "status": 1, "created": 1704614400, "lastmodified": 1704614400},
+			}
+
+			if q.Get("sort") == "DESC" {
+				slices.Reverse(items)
+			}
+
+			return map[string]any{
+				"_results": "items",
+				"items":    items,
+			}, nil
+		},
+		"sfomuseum.collection.objects.getImages": func(q url.Values) (map[string]any, error) {
+			return map[string]any{
+				"_results": "images",
+				"images":   images[q.Get("object_id")],
+			}, nil
+		},
+		"sfomuseum.collection.objects.getInfo": func(q url.Values) (map[string]any, error) {
+			return map[string]any{
+				"object": map[string]any{
+					"wof:id":           103,
+					"title":            "Airline travel poster: United Air Lines, Hawaii",
+					"date":             "c. 1950",
+					"accession_number": "2011.032.0001",
+					"url":              "https://collection.sfomuseum.org/objects/103/",
+				},
+			}, nil
+		},
+		"sfomuseum.millsfield.instagram.getInfo": func(q url.Values) (map[string]any, error) {
+			return map[string]any{
+				"post": map[string]any{
+					"wof:id":          2010,
+					"sfomuseum:image": "https://static.sfomuseum.org/media/2010_ig_b.jpg",
+					"taken":           1516300000,
+					"caption": map[string]any{
+						"excerpt": "Smile for the camera!",
+					},
+				},
+			}, nil
+		},
+	}
+}
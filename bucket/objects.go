@@ -0,0 +1,437 @@
+package bucket
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	pb_bucket "github.com/aaronland/go-picturebook/bucket"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+)
+
+// OBJECTS_FORMAT_CSV is the format for files containing comma-separated values.
+const OBJECTS_FORMAT_CSV string = "csv"
+
+// OBJECTS_FORMAT_JSON is the format for files containing a JSON-encoded list.
+const OBJECTS_FORMAT_JSON string = "json"
+
+// OBJECTS_FORMAT_TEXT is the format for files containing one identifier per line.
+const OBJECTS_FORMAT_TEXT string = "text"
+
+// errObjectNotFound is the error returned when an accession number does not correspond to an object.
+var errObjectNotFound = errors.New("Object not found")
+
+// objectsPageSize is the number of identifiers resolved, and yielded, in each batch of items.
+const objectsPageSize int = 50
+
+// ObjectsBucket implements the `aaronland/go-picturebook/bucket.Bucket` interface for use with the images for a list of objects, identified
+// by their object IDs or accession numbers, in the SFO Museum Aviation Collection.
+type ObjectsBucket struct {
+	*ShoeboxBucket
+	path   string
+	format string
+	column string
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := registerBucket(ctx, "objects", NewObjectsBucket)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewObjectsBucket returns a new `ObjectsBucket` instance implementing the `aaronland/go-picturebook/bucket.Bucket` interface for use with
+// the images for a list of objects, identified by their object IDs or accession numbers, in the SFO Museum Aviation Collection. 'uri' is
+// expected to take the form of:
+//
+//	objects://?file={PATH}&{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?file={PATH}` The path to a local file containing a list of object IDs and/or accession numbers. Required.
+// - `?format={FORMAT}` The format of the file. Valid options are "csv", "json" (a list of numbers or strings) or "text" (one identifier
+// per line, ignoring empty lines and lines starting with "#"). Default is derived from the file's extension, or "text" if it is not
+// ".csv" or ".json".
+// - `?column={NAME_OR_INDEX}` The name, or (zero-indexed) position, of the column containing identifiers in a CSV file. If a name is
+// specified the first row of the file is treated as a header. Default is the first column, in which case the first row is skipped if it
+// does not contain any digits (for example a header).
+// - `?limit={N}` The maximum number of objects to include.
+// - Any of the parameters for the `shoebox://` bucket, except those used to select shoebox items, for the API endpoint, HTTP requests,
+// resolving images, error handling, reports, deduplication, checkpoints and caching. See `NewShoeboxBucket` for details. The
+// `?status=`, `?sample=` and `?seed=` parameters, which only apply to shoebox items, are reported as an error.
+//
+// Identifiers which only contain digits are treated as object IDs and any other identifier as an accession number. Accession numbers
+// are resolved to object IDs using the `sfomuseum.collection.objects.getInfo` API method. Objects are included in the order they are
+// listed in the file and their images are resolved in the same way as objects in a shoebox. Accession numbers which don't correspond to
+// an object are recorded in the bucket's report (as "unresolved") as are objects which can not be resolved or don't have any images. Any
+// other failure to look up an accession number (for example an invalid access token) stops gathering pictures.
+func NewObjectsBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	path := q.Get("file")
+
+	if path == "" {
+		return nil, fmt.Errorf("Missing ?file= parameter")
+	}
+
+	format := OBJECTS_FORMAT_TEXT
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		format = OBJECTS_FORMAT_CSV
+	case ".json":
+		format = OBJECTS_FORMAT_JSON
+	}
+
+	if q.Has("format") {
+
+		v := strings.ToLower(q.Get("format"))
+
+		switch v {
+		case OBJECTS_FORMAT_CSV, OBJECTS_FORMAT_JSON, OBJECTS_FORMAT_TEXT:
+			format = v
+		default:
+			return nil, fmt.Errorf("Invalid ?format= parameter, must be '%s', '%s' or '%s'", OBJECTS_FORMAT_CSV, OBJECTS_FORMAT_JSON, OBJECTS_FORMAT_TEXT)
+		}
+	}
+
+	err = rejectShoeboxSelectParameters(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shoebox_b, err := newShoeboxBucket(ctx, u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b := &ObjectsBucket{
+		ShoeboxBucket: shoebox_b,
+		path:          path,
+		format:        format,
+		column:        q.Get("column"),
+	}
+
+	return b, nil
+}
+
+// GatherPictures returns a new `iter.Seq2[string, error]` instance containing the URIs for the images of the objects listed in the
+// bucket's file, in the order they are listed. Objects which can not be resolved are handled, reported and checkpointed in the same
+// way as shoebox items. See `ShoeboxBucket.GatherPictures` for details.
+func (b *ObjectsBucket) GatherPictures(ctx context.Context, uris ...string) iter.Seq2[string, error] {
+
+	// https://api.sfomuseum.org/methods/sfomuseum.collection.objects.getInfo
+	// https://api.sfomuseum.org/methods/sfomuseum.collection.objects.getImages
+
+	return b.gather(ctx, b.fileItems)
+}
+
+// fileItems is a `shoeboxItemSource` for the objects listed in the bucket's file.
+func (b *ObjectsBucket) fileItems(ctx context.Context) (map[uint8]shoebox.Resolver, shoeboxItemsLister, error) {
+
+	ids, err := readObjectIdentifiers(b.path, b.format, b.column)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolvers, type_id, err := b.objectResolvers(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b.items_opts.limit > 0 && len(ids) > b.items_opts.limit {
+		ids = ids[:b.items_opts.limit]
+	}
+
+	list := func(start int, count int) iter.Seq2[*shoeboxItemsPage, error] {
+
+		return func(yield func(*shoeboxItemsPage, error) bool) {
+
+			// Identifiers on the preceding pages are not resolved again
+
+			for offset := (start - 1) * objectsPageSize; offset < len(ids); offset += objectsPageSize {
+
+				p := &shoeboxItemsPage{
+					page:       offset/objectsPageSize + 1,
+					items:      make([]*response.ShoeboxListItem, 0),
+					unresolved: make([]string, 0),
+				}
+
+				for _, id := range ids[offset:min(offset+objectsPageSize, len(ids))] {
+
+					object_id, err := resolveObjectIdentifier(ctx, b.api_client, id)
+
+					if err != nil {
+
+						// Only identifiers which don't exist are reported as unresolved. Any other error (for example
+						// an invalid access token or an API request which keeps failing) means the list can't be
+						// resolved at all so it is treated the same way as failing to list the items in a shoebox.
+
+						if !errors.Is(err, errObjectNotFound) {
+							yield(nil, fmt.Errorf("Failed to resolve object identifier '%s', %w", id, err))
+							return
+						}
+
+						slog.Warn("Failed to resolve object identifier, skipping", "id", id, "error", err)
+						p.unresolved = append(p.unresolved, id)
+						continue
+					}
+
+					i := &response.ShoeboxListItem{
+						Id:     object_id,
+						ItemId: object_id,
+						TypeId: type_id,
+					}
+
+					p.items = append(p.items, i)
+				}
+
+				if !yield(p, nil) {
+					return
+				}
+			}
+		}
+	}
+
+	return resolvers, list, nil
+}
+
+// readObjectIdentifiers returns the list of object identifiers in the file at 'path' which is encoded as 'format'. If 'format' is
+// `OBJECTS_FORMAT_CSV` identifiers are read from the column identified by 'column'. See `NewObjectsBucket` for details.
+func readObjectIdentifiers(path string, format string, column string) ([]string, error) {
+
+	r, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s, %w", path, err)
+	}
+
+	defer r.Close()
+
+	var ids []string
+
+	switch format {
+	case OBJECTS_FORMAT_CSV:
+		ids, err = readObjectIdentifiersCSV(r, column)
+	case OBJECTS_FORMAT_JSON:
+		ids, err = readObjectIdentifiersJSON(r)
+	default:
+		ids, err = readObjectIdentifiersText(r)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read object identifiers from %s, %w", path, err)
+	}
+
+	return ids, nil
+}
+
+// readObjectIdentifiersText returns the list of object identifiers, one per line, in 'r' ignoring empty lines and lines starting with "#".
+func readObjectIdentifiersText(r io.Reader) ([]string, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+
+	for _, ln := range strings.Split(string(body), "\n") {
+
+		ln = strings.TrimSpace(ln)
+
+		if ln == "" || strings.HasPrefix(ln, "#") {
+			continue
+		}
+
+		ids = append(ids, ln)
+	}
+
+	return ids, nil
+}
+
+// readObjectIdentifiersJSON returns the list of object identifiers in 'r' which is expected to contain a JSON-encoded list of numbers or strings.
+func readObjectIdentifiersJSON(r io.Reader) ([]string, error) {
+
+	var values []any
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	err := dec.Decode(&values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+
+	for idx, v := range values {
+
+		switch v := v.(type) {
+		case json.Number:
+			ids = append(ids, v.String())
+		case string:
+
+			v = strings.TrimSpace(v)
+
+			if v != "" {
+				ids = append(ids, v)
+			}
+
+		default:
+			return nil, fmt.Errorf("Invalid identifier at offset %d, must be a number or a string", idx)
+		}
+	}
+
+	return ids, nil
+}
+
+// readObjectIdentifiersCSV returns the list of object identifiers in 'r', which contains comma-separated values, from the column identified
+// by 'column'. See `NewObjectsBucket` for details.
+func readObjectIdentifiersCSV(r io.Reader, column string) ([]string, error) {
+
+	csv_r := csv.NewReader(r)
+	csv_r.FieldsPerRecord = -1
+
+	rows, err := csv_r.ReadAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return []string{}, nil
+	}
+
+	idx := 0
+
+	if column != "" {
+
+		v, err := strconv.Atoi(column)
+
+		if err == nil {
+			idx = v
+		} else {
+
+			idx = -1
+
+			for i, name := range rows[0] {
+
+				if strings.EqualFold(strings.TrimSpace(name), column) {
+					idx = i
+					break
+				}
+			}
+
+			if idx == -1 {
+				return nil, fmt.Errorf("Missing column '%s'", column)
+			}
+
+			rows = rows[1:]
+		}
+	}
+
+	if idx < 0 {
+		return nil, fmt.Errorf("Invalid column '%s'", column)
+	}
+
+	ids := make([]string, 0)
+
+	for row_idx, row := range rows {
+
+		if idx >= len(row) {
+			continue
+		}
+
+		v := strings.TrimSpace(row[idx])
+
+		if v == "" {
+			continue
+		}
+
+		// Skip a header row when the column was not identified by name
+
+		if row_idx == 0 && column == "" && !strings.ContainsAny(v, "0123456789") {
+			continue
+		}
+
+		ids = append(ids, v)
+	}
+
+	return ids, nil
+}
+
+// resolveObjectIdentifier returns the object ID for 'id' which is either an object ID or an accession number. Accession numbers are
+// resolved using the `sfomuseum.collection.objects.getInfo` API method. If an accession number does not correspond to an object
+// `errObjectNotFound` is returned.
+func resolveObjectIdentifier(ctx context.Context, api_client client.Client, id string) (int64, error) {
+
+	object_id, err := strconv.ParseInt(id, 10, 64)
+
+	if err == nil {
+		return object_id, nil
+	}
+
+	args := &url.Values{}
+	args.Set("method", "sfomuseum.collection.objects.getInfo")
+	args.Set("accession_number", id)
+
+	rsp, err := api_client.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+
+		var status_err *api.StatusError
+
+		if errors.As(err, &status_err) && status_err.StatusCode == http.StatusNotFound {
+			return 0, errObjectNotFound
+		}
+
+		return 0, fmt.Errorf("Failed to execute sfomuseum.collection.objects.getInfo method, %w", err)
+	}
+
+	defer rsp.Close()
+
+	var obj_rsp *response.ObjectInfoResponse
+
+	dec := json.NewDecoder(rsp)
+	err = dec.Decode(&obj_rsp)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to unmarshal object response, %w", err)
+	}
+
+	if obj_rsp.Object == nil || obj_rsp.Object.Id == 0 {
+		return 0, errObjectNotFound
+	}
+
+	return obj_rsp.Object.Id, nil
+}
@@ -0,0 +1,176 @@
+package bucket
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/report"
+)
+
+func TestReadObjectIdentifiers(t *testing.T) {
+
+	tests := map[string]string{
+		"ids.txt":  "# loan request\n2015.166.0309\n\n101\n",
+		"ids.csv":  "accession_number,notes\n2015.166.0309,\"frame, glass\"\n101,\n",
+		"ids.json": `["2015.166.0309", 101]`,
+	}
+
+	expected := []string{"2015.166.0309", "101"}
+
+	for fname, body := range tests {
+
+		path := filepath.Join(t.TempDir(), fname)
+
+		err := os.WriteFile(path, []byte(body), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", fname, err)
+		}
+
+		format := strings.TrimPrefix(filepath.Ext(fname), ".")
+
+		if format == "txt" {
+			format = OBJECTS_FORMAT_TEXT
+		}
+
+		ids, err := readObjectIdentifiers(path, format, "")
+
+		if err != nil {
+			t.Fatalf("Failed to read identifiers from %s, %v", fname, err)
+		}
+
+		if !slices.Equal(ids, expected) {
+			t.Fatalf("Unexpected identifiers for %s: %v", fname, ids)
+		}
+	}
+
+	ids, err := readObjectIdentifiersCSV(strings.NewReader("notes,accession_number\nframed,2015.166.0309\n"), "accession_number")
+
+	if err != nil {
+		t.Fatalf("Failed to read identifiers from named column, %v", err)
+	}
+
+	if !slices.Equal(ids, []string{"2015.166.0309"}) {
+		t.Fatalf("Unexpected identifiers for named column: %v", ids)
+	}
+}
+
+func TestObjectsBucket(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	methods["sfomuseum.collection.objects.getInfo"] = func(q url.Values) (map[string]any, error) {
+
+		if q.Get("accession_number") != "2015.166.0309" {
+			return map[string]any{}, nil
+		}
+
+		return map[string]any{
+			"object": map[string]any{
+				"wof:id":           102,
+				"accession_number": "2015.166.0309",
+			},
+		}, nil
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	ids_path := filepath.Join(t.TempDir(), "ids.txt")
+	report_path := filepath.Join(t.TempDir(), "report.json")
+
+	err := os.WriteFile(ids_path, []byte("2015.166.0309\n1999.999.9999\n101\n103\n"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write identifiers, %v", err)
+	}
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("file", ids_path)
+	q.Set("images", "primary")
+	q.Set("cards", "false")
+	q.Set("report", report_path)
+
+	b, err := NewObjectsBucket(ctx, fmt.Sprintf("objects://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create objects bucket, %v", err)
+	}
+
+	uris := make([]string, 0)
+
+	for uri, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to gather pictures, %v", err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	expected := []string{
+		"https://static.sfomuseum.org/media/1021_k_k.jpg#v1;label=o;id=102;item=102;image=1021;size=k;type=object",
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=101;item=101;image=1011;size=k;type=object",
+	}
+
+	if !slices.Equal(uris, expected) {
+		t.Fatalf("Unexpected pictures: %v", uris)
+	}
+
+	rpt, err := report.ReadFile(report_path)
+
+	if err != nil {
+		t.Fatalf("Failed to read report, %v", err)
+	}
+
+	if !slices.Equal(rpt.Unresolved, []string{"1999.999.9999"}) {
+		t.Fatalf("Unexpected unresolved identifiers: %v", rpt.Unresolved)
+	}
+
+	// Accession numbers which can't be looked up (rather than don't exist) fail
+
+	methods["sfomuseum.collection.objects.getInfo"] = func(q url.Values) (map[string]any, error) {
+		return nil, fmt.Errorf("Invalid access token")
+	}
+
+	broken_server := newMockAPIServer(t, methods)
+	defer broken_server.Close()
+
+	q.Set("api", broken_server.URL)
+
+	b, err = NewObjectsBucket(ctx, fmt.Sprintf("objects://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create objects bucket, %v", err)
+	}
+
+	var gather_err error
+
+	for _, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			gather_err = err
+			break
+		}
+	}
+
+	if gather_err == nil {
+		t.Fatalf("Expected gathering pictures with a failing API to fail")
+	}
+
+	_, err = NewObjectsBucket(ctx, "objects://?token=TOKEN")
+
+	if err == nil {
+		t.Fatalf("Expected objects bucket without a file to fail")
+	}
+}
@@ -2,44 +2,144 @@ package bucket
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"iter"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	pb_bucket "github.com/aaronland/go-picturebook/bucket"
-	"github.com/jtacoma/uritemplates"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/dates"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/media"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/report"
 	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/transport"
 	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
-	"github.com/tidwall/gjson"
 	"github.com/whosonfirst/go-ioutil"
 )
 
+// ON_ERROR_FAIL is the error policy to stop gathering pictures when a shoebox item can not be resolved.
+const ON_ERROR_FAIL string = "fail"
+
+// ON_ERROR_SKIP is the error policy to skip the pictures for a shoebox item which can not be resolved.
+const ON_ERROR_SKIP string = "skip"
+
+// ON_ERROR_PLACEHOLDER is the error policy to replace shoebox items which can not be resolved with a (generated) card image.
+const ON_ERROR_PLACEHOLDER string = "placeholder"
+
 // ShoeboxBucket implements the `aaronland/go-picturebook/bucket.Bucket` interface for use with object images in a SFO Museum "shoebox".
 type ShoeboxBucket struct {
 	pb_bucket.Bucket
-	api_client client.Client
-	min_date   int64
-	max_date   int64
+	api_client  client.Client
+	http_client *http.Client
+	media_hosts *media.Hosts
+	min_date    int64
+	max_date    int64
+	resolvers   map[string]shoebox.Resolver
+	workers     int
+	cache       *media.Cache
+	cards       bool
+	items_opts  *shoeboxItemsOptions
+	on_error    string
+	report_path string
+	checkpoint  *shoeboxCheckpointStore
+	dedupe      string
+	dedupe_dist int
 }
 
 func init() {
 
 	ctx := context.Background()
-	err := pb_bucket.RegisterBucket(ctx, "shoebox", NewShoeboxBucket)
+	err := registerBucket(ctx, "shoebox", NewShoeboxBucket)
 
 	if err != nil {
 		panic(err)
 	}
 }
 
+// schemes are the URI schemes of the `aaronland/go-picturebook/bucket.Bucket` implementations defined by this package.
+var schemes = make([]string, 0)
+
+// registerBucket registers 'scheme' and 'init_func' with the `aaronland/go-picturebook/bucket` package and records
+// 'scheme' as one of the schemes defined by this package.
+func registerBucket(ctx context.Context, scheme string, init_func pb_bucket.BucketInitializationFunc) error {
+
+	err := pb_bucket.RegisterBucket(ctx, scheme, init_func)
+
+	if err != nil {
+		return err
+	}
+
+	schemes = append(schemes, scheme)
+	return nil
+}
+
+// Schemes returns the (sorted) list of URI schemes for the `aaronland/go-picturebook/bucket.Bucket` implementations defined by this package.
+// These are the buckets which understand the common parameters (for example `?token=` or `?report=`) described in `NewShoeboxBucket`.
+func Schemes() []string {
+
+	s := slices.Clone(schemes)
+	slices.Sort(s)
+	return s
+}
+
 // NewShoeboxBucket returns a new `ShoeboxBucket` instance implementing the `aaronland/go-picturebook/bucket.Bucket` interface for use with object images in a SFO Museum "shoebox".
+// 'uri' is expected to take the form of:
+//
+//	shoebox://?{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?token={TOKEN}` A valid SFO Museum API access token.
+// - `?api={URI}` An optional SFO Museum API endpoint. See `api.NewClientWithQuery` for details, including how API requests are retried.
+// - `?insecure={BOOLEAN}` An optional boolean flag signaling that TLS verification for the API endpoint should be skipped.
+// - `?max_attempts={N}`, `?backoff={DURATION}`, `?max_backoff={DURATION}` and `?rate_limit={N}` Options for retrying (and rate limiting)
+// image requests which fail with a transient error. See `transport.OptionsWithQuery` for details.
+// - `?connect_timeout={DURATION}`, `?read_timeout={DURATION}`, `?timeout={DURATION}`, `?proxy={URL}`, `?ca_bundle={PATH}` and `?user_agent={STRING}`
+// Options for the timeouts, proxy, additional certificate authorities and User-Agent header used by image requests. See `transport.OptionsWithQuery` for details.
+// - `?media_host={HOST}` Zero or more hosts or URI prefixes from which images may be retrieved. See `media.NewHosts` for details.
+// - `?tz={TIMEZONE}` The timezone used to derive the boundaries of dates and date ranges. Default is "America/Los_Angeles".
+// - `?year={YYYY}` Limit shoebox items to those collected during a specific year.
+// - `?date={DATE_RANGE}` Limit shoebox items to those collected during a date or date range. See `dates.ParseRange` for details.
+// - `?min_date={DATE}` Limit shoebox items to those collected on or after the start of this date. See `dates.ParseRange` for details.
+// - `?max_date={DATE}` Limit shoebox items to those collected on or before the end of this date. See `dates.ParseRange` for details.
+// - `?min={UNIX_TIMESTAMP}` Limit shoebox items to those collected on or after this date.
+// - `?max={UNIX_TIMESTAMP}` Limit shoebox items to those collected on or before this date.
+// - `?sort={ORDER}` The order in which shoebox items are listed. Valid options are "asc" (oldest first) or "desc" (newest first). Default is "asc".
+// - `?status={CODE}` Zero or more (comma-separated) numeric shoebox item status codes. If present only items with one of these status codes will be included.
+// - `?limit={N}` The maximum number of shoebox items to include.
+// - `?sample={N}` Include a random sample of N shoebox items (listed in the order defined by `?sort=`).
+// - `?seed={N}` An optional seed for randomly sampling shoebox items. Using the same seed will produce the same sample (for the same shoebox).
+// - `?workers={N}` The maximum number of shoebox items to resolve concurrently. Default is 1.
+// - `?cards={BOOLEAN}` If true shoebox items without any usable images (for example items whose type is not supported) will be
+// included as generated "card" images derived from the item's metadata. Default is true.
+// - `?on_error={POLICY}` How to handle shoebox items (or their images) which can not be resolved. Valid options are "fail" (stop gathering
+// pictures), "skip" (skip the item or image) or "placeholder" (replace the item with a generated "card" image). Default is "skip".
+// - `?report={PATH}` An optional path to a local file where a JSON-encoded `report.Report` summarizing the shoebox items which were
+// skipped or replaced by placeholders will be written once all the pictures have been gathered.
+// - `?dedupe={MODE}` An optional mode for suppressing duplicate pictures. Valid options are "url" (pictures with the same URI) or "phash"
+// (pictures with the same URI or similar perceptual hashes). The first occurrence of a picture is kept and any duplicates are recorded in the report.
+// Perceptual hashes which are not known by resolvers are derived from (a smaller version of) the picture itself, so "phash" should be used with `?cache=`.
+// - `?dedupe_distance={N}` The maximum Hamming distance between the perceptual hashes of two pictures for them to be considered duplicates. Default is 6.
+// - `?checkpoint={PATH_OR_GOCLOUD_BUCKET_URI}` An optional path to a local file, or a (URL-escaped) `gocloud.dev/blob.Bucket` URI,
+// where progress (the last page of shoebox items and the last item processed, as well as the pictures for the items which have already
+// been resolved) is recorded while gathering pictures. If gathering pictures is interrupted a later run with the same bucket URI (ignoring
+// the access token) will resume listing shoebox items from the last page processed and reuse the pictures for the items which have already
+// been resolved, unless the items on that page have been modified since. If items are sampled the seed used to sample them is recorded so
+// that a resumed run draws the same sample. The checkpoint is not removed once all the pictures have been gathered. See `RemoveCheckpoint`
+// for details.
+// - `?cache={GOCLOUD_BUCKET_URI}` An optional (URL-escaped) `gocloud.dev/blob.Bucket` URI where images will be cached between runs. Note that
+// the relevant `gocloud.dev/blob` driver (for example `gocloud.dev/blob/fileblob`) must be imported by your application.
+// - `?cache_revalidate={BOOLEAN}` If true cached images will be revalidated using conditional HTTP requests. Default is false.
+// - Any of the parameters for the shoebox item resolvers registered with the `shoebox` package. For example `?images={MODE}` and the
+// size preference parameters described in `shoebox.NewObjectResolver`.
 func NewShoeboxBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error) {
 
 	u, err := url.Parse(uri)
@@ -48,44 +148,207 @@ func NewShoeboxBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error)
 		return nil, fmt.Errorf("Failed to parse URI, %w", err)
 	}
 
-	q := u.Query()
-	token := q.Get("token")
+	b, err := newShoeboxBucket(ctx, u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
 
-	client_uri := fmt.Sprintf("oauth2://?access_token=%s", token)
+// newShoeboxBucket returns a new `ShoeboxBucket` instance derived from the bucket URI 'u'. See `NewShoeboxBucket` for details.
+func newShoeboxBucket(ctx context.Context, u *url.URL) (*ShoeboxBucket, error) {
 
-	api_client, err := client.NewClient(ctx, client_uri)
+	q := shoeboxParameters(u)
+
+	api_client, err := api.NewClientWithQuery(ctx, q)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create new client, %w", err)
 	}
 
+	http_client, err := transport.NewHTTPClientWithQuery(q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create HTTP client, %w", err)
+	}
+
+	media_hosts, err := media.NewHosts(q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive media hosts, %w", err)
+	}
+
+	resolvers, err := shoebox.NewResolvers(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create shoebox resolvers, %w", err)
+	}
+
+	items_opts, err := newShoeboxItemsOptions(q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive shoebox item options, %w", err)
+	}
+
 	b := &ShoeboxBucket{
-		api_client: api_client,
+		api_client:  api_client,
+		http_client: http_client,
+		media_hosts: media_hosts,
+		resolvers:   resolvers,
+		workers:     1,
+		cards:       true,
+		items_opts:  items_opts,
+		on_error:    ON_ERROR_SKIP,
+		report_path: q.Get("report"),
+		dedupe_dist: DEFAULT_DEDUPE_DISTANCE,
 	}
 
-	if q.Has("year") {
+	if q.Has("workers") {
 
-		year := q.Get("year")
+		v, err := strconv.Atoi(q.Get("workers"))
 
-		str_start := fmt.Sprintf("%s-01-01 00:00:00", year)
-		str_end := fmt.Sprintf("%s-12-31 23:59:59", year)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?workers= parameter, %w", err)
+		}
 
-		layout := time.DateTime
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid ?workers= parameter, must be greater than zero")
+		}
 
-		t1, err := time.Parse(layout, str_start)
+		b.workers = v
+	}
+
+	if q.Has("cards") {
+
+		v, err := strconv.ParseBool(q.Get("cards"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?cards= parameter, %w", err)
+		}
+
+		b.cards = v
+	}
+
+	if q.Has("on_error") {
+
+		v := strings.ToLower(q.Get("on_error"))
+
+		switch v {
+		case ON_ERROR_FAIL, ON_ERROR_SKIP, ON_ERROR_PLACEHOLDER:
+			b.on_error = v
+		default:
+			return nil, fmt.Errorf("Invalid ?on_error= parameter, must be '%s', '%s' or '%s'", ON_ERROR_FAIL, ON_ERROR_SKIP, ON_ERROR_PLACEHOLDER)
+		}
+	}
+
+	if q.Has("dedupe") {
+
+		v := strings.ToLower(q.Get("dedupe"))
+
+		switch v {
+		case DEDUPE_URL, DEDUPE_PHASH:
+			b.dedupe = v
+		default:
+			return nil, fmt.Errorf("Invalid ?dedupe= parameter, must be '%s' or '%s'", DEDUPE_URL, DEDUPE_PHASH)
+		}
+	}
+
+	if q.Has("dedupe_distance") {
+
+		v, err := strconv.Atoi(q.Get("dedupe_distance"))
 
 		if err != nil {
-			return nil, fmt.Errorf("Failed to parse start date, %w", err)
+			return nil, fmt.Errorf("Failed to parse ?dedupe_distance= parameter, %w", err)
+		}
+
+		if v < 0 || v > 64 {
+			return nil, fmt.Errorf("Invalid ?dedupe_distance= parameter, must be between 0 and 64")
+		}
+
+		b.dedupe_dist = v
+	}
+
+	if q.Has("checkpoint") {
+
+		checkpoint, err := newShoeboxCheckpointStore(ctx, q.Get("checkpoint"), checkpointKey(u))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create checkpoint store, %w", err)
+		}
+
+		b.checkpoint = checkpoint
+	}
+
+	if q.Has("cache") {
+
+		revalidate := false
+
+		if q.Has("cache_revalidate") {
+
+			v, err := strconv.ParseBool(q.Get("cache_revalidate"))
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse ?cache_revalidate= parameter, %w", err)
+			}
+
+			revalidate = v
 		}
 
-		t2, err := time.Parse(layout, str_end)
+		cache, err := media.NewCache(ctx, q.Get("cache"), revalidate)
 
 		if err != nil {
-			return nil, fmt.Errorf("Failed to parse end date, %w", err)
+			return nil, fmt.Errorf("Failed to create media cache, %w", err)
 		}
 
-		b.min_date = t1.Unix()
-		b.max_date = t2.Unix()
+		b.cache = cache
+	}
+
+	loc, err := dates.LoadLocation(q.Get("tz"))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse ?tz= parameter, %w", err)
+	}
+
+	now := time.Now()
+
+	for _, k := range []string{"year", "date"} {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		r, err := dates.ParseRange(q.Get(k), loc, now)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?%s= parameter, %w", k, err)
+		}
+
+		b.min_date = r.MinDate()
+		b.max_date = r.MaxDate()
+	}
+
+	if q.Has("min_date") {
+
+		r, err := dates.ParseRange(q.Get("min_date"), loc, now)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?min_date= parameter, %w", err)
+		}
+
+		b.min_date = r.MinDate()
+	}
+
+	if q.Has("max_date") {
+
+		r, err := dates.ParseRange(q.Get("max_date"), loc, now)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?max_date= parameter, %w", err)
+		}
+
+		b.max_date = r.MaxDate()
 	}
 
 	if q.Has("min") {
@@ -114,226 +377,450 @@ func NewShoeboxBucket(ctx context.Context, uri string) (pb_bucket.Bucket, error)
 }
 
 // GatherPictures returns a new `iter.Seq2[string, error]` instance containing the URIs for object images in a SFO Museum "shoebox".
+// Shoebox items which can not be resolved are handled according to the bucket's `?on_error=` policy and, if a `?report=` path was
+// defined, a summary of the items which were skipped or replaced by placeholders is written once all the pictures have been gathered.
+// If a `?checkpoint=` was defined progress is recorded after each page of shoebox items and gathering resumes from that checkpoint.
+// Shoebox items are listed again starting from the last page recorded in the checkpoint. If the items on that page have been modified
+// (their `lastmodified` values have changed) since being recorded all the shoebox items are listed again from the beginning and only the
+// pictures for items which have not been modified are reused.
 func (b *ShoeboxBucket) GatherPictures(ctx context.Context, uris ...string) iter.Seq2[string, error] {
 
 	// https://api.sfomuseum.org/methods/sfomuseum.you.shoebox.listItems
 	// https://api.sfomuseum.org/methods/sfomuseum.collection.objects.getImages
 
+	return b.gather(ctx, b.shoeboxItems)
+}
+
+// shoeboxItemSource returns the resolvers, keyed by type ID, and a `shoeboxItemsLister` for the (shoebox) items to gather pictures for.
+// It allows other buckets to share the logic for resolving, reporting, deduplicating and checkpointing pictures used by `ShoeboxBucket`.
+type shoeboxItemSource func(ctx context.Context) (map[uint8]shoebox.Resolver, shoeboxItemsLister, error)
+
+// shoeboxItemsLister returns an iterator of batches of (shoebox) items, one batch per page of results, starting at page 'start' where
+// 'count' items were included from the preceding pages. Listers which can not start at a given page may yield the preceding pages too.
+type shoeboxItemsLister func(start int, count int) iter.Seq2[*shoeboxItemsPage, error]
+
+// gather returns a new `iter.Seq2[string, error]` instance containing the URIs for the pictures derived from the items returned by 'src'.
+func (b *ShoeboxBucket) gather(ctx context.Context, src shoeboxItemSource) iter.Seq2[string, error] {
+
 	return func(yield func(string, error) bool) {
 
-		types_args := &url.Values{}
-		types_args.Set("method", "sfomuseum.you.shoebox.typesMap")
+		rpt := report.NewReport()
 
-		types_r, err := b.api_client.ExecuteMethod(ctx, http.MethodGet, types_args)
+		if b.report_path != "" {
 
-		if err != nil {
-			yield("", err)
-			return
+			defer func() {
+
+				err := rpt.WriteFile(b.report_path)
+
+				if err != nil {
+					slog.Error("Failed to write shoebox report", "path", b.report_path, "error", err)
+				}
+			}()
 		}
 
-		defer types_r.Close()
+		var cp *shoeboxCheckpoint
 
-		var types_map_rsp *response.ShoeboxTypesMapResponse
+		if b.checkpoint != nil {
 
-		dec := json.NewDecoder(types_r)
-		err = dec.Decode(&types_map_rsp)
+			v, err := b.checkpoint.read(ctx)
 
-		if err != nil {
-			yield("", err)
+			if err != nil {
+				slog.Warn("Failed to read checkpoint, starting from the beginning", "error", err)
+			}
+
+			// Sampled items can only be resumed if the checkpoint records the seed they were sampled with
+
+			if v != nil && b.items_opts.sample > 0 && v.Seed == nil {
+				slog.Warn("Checkpoint does not record the seed used to sample shoebox items, starting from the beginning")
+				v = nil
+			}
+
+			if v != nil {
+
+				slog.Info("Resuming from checkpoint", "page", v.Page, "item id", v.ItemId, "items", len(v.Items))
+				cp = v
+
+				if cp.Seed != nil {
+					b.items_opts.seed = *cp.Seed
+				}
+
+			} else {
+
+				cp = newShoeboxCheckpoint(b.checkpoint.key)
+
+				if b.items_opts.sample > 0 {
+					seed := b.items_opts.seed
+					cp.Seed = &seed
+				}
+			}
+		}
+
+		b.gatherPictures(ctx, src, rpt, cp, yield)
+
+		if cp == nil {
 			return
 		}
 
-		types_map := types_map_rsp.Types
+		// Update the checkpoint even if gathering pictures was cancelled so that it can be resumed. The checkpoint
+		// is not removed once all the pictures have been gathered since they still need to be processed by the
+		// application using the bucket. See `RemoveCheckpoint` for details.
+
+		err := b.checkpoint.write(context.WithoutCancel(ctx), cp)
+
+		if err != nil {
+			slog.Error("Failed to update checkpoint", "error", err)
+		}
+	}
+}
+
+// shoeboxItems is a `shoeboxItemSource` for the items in a SFO Museum "shoebox".
+func (b *ShoeboxBucket) shoeboxItems(ctx context.Context) (map[uint8]shoebox.Resolver, shoeboxItemsLister, error) {
+
+	resolvers, _, err := b.typeResolvers(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list_args := &url.Values{}
+
+	if b.min_date > 0 {
+		list_args.Set("min_date", strconv.FormatInt(b.min_date, 10))
+	}
+
+	if b.max_date > 0 {
+		list_args.Set("max_date", strconv.FormatInt(b.max_date, 10))
+	}
+
+	return resolvers, listItems(ctx, b.api_client, list_args, b.items_opts), nil
+}
+
+// typeResolvers returns the bucket's resolvers keyed by shoebox type ID as well as the dictionary of shoebox type names and
+// their numeric identifiers they were derived from.
+func (b *ShoeboxBucket) typeResolvers(ctx context.Context) (map[uint8]shoebox.Resolver, map[string]uint8, error) {
+
+	types_map, err := shoebox.TypesMap(ctx, b.api_client)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Map shoebox type IDs to their resolvers rather than hardcoding things...
+
+	resolvers := make(map[uint8]shoebox.Resolver)
+
+	for name, type_id := range types_map {
+
+		r, exists := b.resolvers[strings.ToLower(name)]
+
+		if exists {
+			resolvers[type_id] = r
+		}
+	}
+
+	return resolvers, types_map, nil
+}
+
+// objectResolvers returns the bucket's resolvers keyed by shoebox type ID as well as the shoebox type ID for objects. It is used by
+// buckets whose items are objects in the SFO Museum Aviation Collection rather than items in a shoebox.
+func (b *ShoeboxBucket) objectResolvers(ctx context.Context) (map[uint8]shoebox.Resolver, uint8, error) {
 
-		//
+	resolvers, types_map, err := b.typeResolvers(ctx)
 
-		list_args := &url.Values{}
-		list_args.Set("method", "sfomuseum.you.shoebox.listItems")
-		list_args.Set("sort", "ASC")
+	if err != nil {
+		return nil, 0, err
+	}
 
-		if b.min_date > 0 {
-			list_args.Set("min_date", strconv.FormatInt(b.min_date, 10))
+	type_id, exists := types_map["object"]
+
+	if !exists {
+		return nil, 0, fmt.Errorf("Failed to derive shoebox type ID for objects")
+	}
+
+	return resolvers, type_id, nil
+}
+
+// gatherPictures yields the URIs for the pictures derived from the items returned by 'src' to 'yield' recording the results for
+// each item in 'rpt' and, if not nil, 'cp'.
+func (b *ShoeboxBucket) gatherPictures(ctx context.Context, src shoeboxItemSource, rpt *report.Report, cp *shoeboxCheckpoint, yield func(string, error) bool) {
+
+	fail := func(err error) {
+		rpt.Fail(err)
+		yield("", err)
+	}
+
+	cancelled := func() bool {
+
+		if ctx.Err() == nil {
+			return false
 		}
 
-		if b.max_date > 0 {
-			list_args.Set("max_date", strconv.FormatInt(b.max_date, 10))
+		fail(fmt.Errorf("Gathering pictures was cancelled, %w", ctx.Err()))
+		return true
+	}
+
+	resolvers, list, err := src(ctx)
+
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var pages iter.Seq2[*shoeboxItemsPage, error]
+
+	if cp != nil {
+		pages = cp.items(list)
+	} else {
+		pages = list(1, 0)
+	}
+
+	resolve := func(ctx context.Context, i *response.ShoeboxListItem) *shoeboxItemResult {
+
+		var r *shoeboxItemResult
+		var exists bool
+
+		if cp != nil {
+			r, exists = cp.result(i)
 		}
 
-		for list_r, err := range client.ExecuteMethodPaginatedWithClient(ctx, b.api_client, http.MethodGet, list_args) {
+		if !exists {
+			r = b.resolveItem(ctx, resolvers, i)
+		}
 
-			if err != nil {
-				yield("", err)
+		// Perceptual hashes are derived concurrently but compared in the order items are listed
+
+		if b.dedupe == DEDUPE_PHASH && r.err == nil {
+			r.phashes = b.perceptualHashes(ctx, r)
+		}
+
+		return r
+	}
+
+	var deduper *shoeboxDeduper
+
+	if b.dedupe != "" {
+		deduper = newShoeboxDeduper(b.dedupe, b.dedupe_dist)
+	}
+
+	for p, err := range pages {
+
+		if cancelled() {
+			return
+		}
+
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		for _, id := range p.unresolved {
+			rpt.AddUnresolved(id)
+		}
+
+		// Items are resolved concurrently but results are yielded in the
+		// same order they were returned by the listItems method.
+
+		for r := range resolveOrdered(ctx, b.workers, p.items, resolve) {
+
+			// Results for items resolved after gathering pictures was cancelled are
+			// likely to be errors caused by the cancellation so they are discarded.
+
+			if cancelled() {
 				return
 			}
 
-			var items_rsp *response.ShoeboxListItemsResponse
+			rpt.AddItems(1)
 
-			dec := json.NewDecoder(list_r)
-			err = dec.Decode(&items_rsp)
+			for _, reason := range r.skipped {
+				rpt.Skip(reason, r.item.Id)
+			}
 
-			if err != nil {
-				yield("", err)
+			if r.placeholder != "" {
+				rpt.Placeholder(r.placeholder, r.item.Id)
+			}
+
+			if r.err != nil {
+				fail(r.err)
 				return
 			}
 
-			for _, i := range items_rsp.Items {
+			for _, uri := range r.uris {
 
-				// fetch type map rather than hardcoding things...
+				if deduper != nil {
 
-				switch i.TypeId {
-				case types_map["object"]:
+					if dup := deduper.check(r.item.Id, uri, r.phashes); dup != nil {
+						slog.Info("Picture is a duplicate, skipping", "uri", uri, "original", dup.OriginalURI)
+						rpt.Duplicate(dup)
+						continue
+					}
+				}
 
-					str_id := strconv.FormatInt(i.ItemId, 10)
+				rpt.AddPictures(1)
 
-					im_args := &url.Values{}
-					im_args.Set("method", "sfomuseum.collection.objects.getImages")
-					im_args.Set("object_id", str_id)
+				if !yield(uri, nil) {
+					return
+				}
+			}
 
-					for im_r, err := range client.ExecuteMethodPaginatedWithClient(ctx, b.api_client, http.MethodGet, im_args) {
+			if cp != nil {
+				cp.update(r)
+			}
+		}
 
-						if err != nil {
+		// resolveOrdered stops (without yielding any more results) if 'ctx' is cancelled
 
-							if !yield("", err) {
-								return
-							}
+		if cancelled() {
+			return
+		}
 
-							continue
-						}
+		if cp != nil {
 
-						// Something something SPR...
+			if b.items_opts.sample == 0 {
+				cp.addPage(p)
+			}
 
-						im_body, err := io.ReadAll(im_r)
+			err := b.checkpoint.write(ctx, cp)
 
-						if err != nil {
-							if !yield("", err) {
-								return
-							}
+			if err != nil {
+				slog.Warn("Failed to write checkpoint", "error", err)
+			}
+		}
+	}
 
-							continue
-						}
+	return
+}
 
-						im_rsp := gjson.GetBytes(im_body, "images")
+// resolveItem resolves the image URIs for shoebox item 'i' using the resolver registered for its type in 'resolvers'. Errors
+// are handled according to the bucket's `?on_error=` policy.
+func (b *ShoeboxBucket) resolveItem(ctx context.Context, resolvers map[uint8]shoebox.Resolver, i *response.ShoeboxListItem) *shoeboxItemResult {
 
-						for im_idx, r := range im_rsp.Array() {
+	r := &shoeboxItemResult{
+		item:    i,
+		uris:    make([]string, 0),
+		skipped: make([]string, 0),
+	}
 
-							logger := slog.Default()
-							logger = logger.With("object", i.ItemId)
-							logger = logger.With("image", im_idx)
+	logger := slog.Default()
+	logger = logger.With("item id", i.ItemId)
+	logger = logger.With("type", i.TypeId)
 
-							template_r := r.Get("media:uri_template")
+	errs := make([]error, 0)
 
-							if !template_r.Exists() {
-								logger.Warn("Image record for object is missing media:uri_template, skipping")
-								continue
-							}
+	resolver, exists := resolvers[i.TypeId]
 
-							sizes_r := r.Get("media:properties.sizes")
+	if exists {
 
-							if !sizes_r.Exists() {
-								logger.Warn("Image record for object is missing media:properties.sizes, skipping")
-								continue
-							}
+		for uri, err := range resolver.Resolve(ctx, i) {
 
-							uri_t, err := uritemplates.Parse(template_r.String())
+			if err != nil {
 
-							if err != nil {
-								logger.Warn("Failed to parse URI template for object image", "error", err)
-								continue
-							}
+				if b.on_error == ON_ERROR_FAIL {
+					r.err = fmt.Errorf("Failed to resolve shoebox item %d, %w", i.Id, err)
+					return r
+				}
 
-							vars := make(map[string]interface{})
+				errs = append(errs, err)
+				continue
+			}
 
-							labels := []string{
-								"o",
-								"k",
-								"b",
-								"c",
-							}
+			r.uris = append(r.uris, uri)
+		}
+	} else {
+		logger.Debug("Item type not supported")
+	}
 
-							for _, label := range labels {
+	if len(errs) > 0 {
 
-								l_rsp := sizes_r.Get(label)
+		for _, err := range errs {
+			logger.Warn("Failed to resolve shoebox item image, skipping", "error", err)
+		}
 
-								if !l_rsp.Exists() {
-									continue
-								}
+		// Items which have at least one usable image are never replaced by a placeholder
 
-								vars["label"] = label
-								vars["secret"] = l_rsp.Get("secret").String()
-								vars["extension"] = l_rsp.Get("extension").String()
-								break
-							}
+		if len(r.uris) > 0 || b.on_error == ON_ERROR_SKIP {
 
-							_, has_label := vars["label"]
+			for _, err := range errs {
+				r.skipped = append(r.skipped, report.ReasonForError(err))
+			}
 
-							if !has_label {
-								logger.Warn("Image missing label after reading sizes, skipping")
-								continue
-							}
+			return r
+		}
 
-							im_uri, err := uri_t.Expand(vars)
+		return b.resolveCard(ctx, r, report.ReasonForError(errs[0]))
+	}
 
-							if err != nil {
-								logger.Warn("Failed to expand URI template vars, skipping", "error", err)
-								continue
-							}
+	if len(r.uris) > 0 {
+		return r
+	}
 
-							fragment := fmt.Sprintf("o:%d:%d:%d", i.Id, i.ItemId, i.Created)
-							image_uri := fmt.Sprintf("%s#%s", im_uri, fragment)
+	reason := report.REASON_NO_IMAGES
 
-							yield(image_uri, nil)
-						}
-					}
+	if !exists {
+		reason = report.REASON_UNSUPPORTED_TYPE
+	}
 
-				case types_map["instagram"]:
+	// Items without any usable images are replaced by a (generated) card unless cards have been disabled
 
-					str_id := strconv.FormatInt(i.ItemId, 10)
+	if !b.cards {
+		logger.Warn("Shoebox item does not have any usable images, skipping", "reason", reason)
+		r.skipped = append(r.skipped, reason)
+		return r
+	}
 
-					ig_args := &url.Values{}
-					ig_args.Set("method", "sfomuseum.millsfield.instagram.getInfo")
-					ig_args.Set("post_id", str_id)
+	return b.resolveCard(ctx, r, reason)
+}
 
-					ig_rsp, err := b.api_client.ExecuteMethod(ctx, http.MethodGet, ig_args)
+// resolveCard replaces the shoebox item in 'r' with a (generated) card image, recording 'reason' as the reason why.
+func (b *ShoeboxBucket) resolveCard(ctx context.Context, r *shoeboxItemResult, reason string) *shoeboxItemResult {
 
-					if err != nil {
-						yield("", fmt.Errorf("Failed to execute sfomuseum.millsfield.instagram.getInfo method, %w", err))
-						return
-					}
+	for uri, err := range b.resolvers[shoebox.CARD_RESOLVER_NAME].Resolve(ctx, r.item) {
 
-					defer ig_rsp.Close()
-					var ig_post_rsp *response.InstagramPostResponse
+		if err != nil {
 
-					dec := json.NewDecoder(ig_rsp)
-					err = dec.Decode(&ig_post_rsp)
+			if b.on_error == ON_ERROR_FAIL {
+				r.err = fmt.Errorf("Failed to create card for shoebox item %d, %w", r.item.Id, err)
+				return r
+			}
 
-					if err != nil {
-						yield("", fmt.Errorf("Failed to unmarshal IG post response, %w", err))
-						return
-					}
+			slog.Warn("Failed to create card for shoebox item, skipping", "item id", r.item.ItemId, "error", err)
+			r.skipped = append(r.skipped, reason)
+			return r
+		}
+
+		r.uris = append(r.uris, uri)
+	}
+
+	r.placeholder = reason
+	return r
+}
 
-					ig_post := ig_post_rsp.Post
+// shoeboxParameterName returns the name of the `shoebox://` bucket parameter for the query parameter 'k' in the bucket URI 'u' and a
+// boolean value indicating whether 'k' is a `shoebox://` bucket parameter at all. For `api://` bucket URIs only the parameters prefixed
+// with `API_SHOEBOX_PREFIX` are `shoebox://` bucket parameters since all the others are passed as arguments to the API method.
+func shoeboxParameterName(u *url.URL, k string) (string, bool) {
 
-					// SFOMuseumImage should not be considered stable yet and may be replaced/removed
+	if u.Scheme != "api" {
+		return k, true
+	}
 
-					// Note the URL fragment. This is necessary (for the time being) since the IG image
-					// URLs don't have any pointers or references to the SFO Museum post ID. We append
-					// that info in the URL fragment here and then dereference it in the caption/shoebox.Text
-					// method.
+	return strings.CutPrefix(k, API_SHOEBOX_PREFIX)
+}
 
-					fragment := fmt.Sprintf("ig:%d:%d:%d", ig_post.WhosOnFirstId, i.ItemId, i.Created)
-					image_uri := fmt.Sprintf("%s#%s", ig_post.SFOMuseumImage, fragment)
+// shoeboxParameters returns the `shoebox://` bucket parameters in the bucket URI 'u'. See `shoeboxParameterName` for details.
+func shoeboxParameters(u *url.URL) url.Values {
 
-					yield(image_uri, nil)
+	q := url.Values{}
 
-				default:
-					slog.Debug("Item type not supported", "item id", i.ItemId, "type", i.TypeId)
-					continue
-				}
+	for k, v := range u.Query() {
 
-			}
-		}
+		name, ok := shoeboxParameterName(u, k)
 
+		if ok {
+			q[name] = v
+		}
 	}
+
+	return q
 }
 
 // NewReader returns a new `io.ReadSeekCloser` instance for an object image identified by 'key' in a SFO Museum "shoebox".
@@ -343,19 +830,56 @@ func (b *ShoeboxBucket) NewReader(ctx context.Context, key string, opts any) (io
 		return nil, fmt.Errorf("Invalid key")
 	}
 
-	rsp, err := http.Get(key)
+	if shoebox.IsGeneratedKey(key) {
+		return b.newGeneratedReader(ctx, key)
+	}
+
+	// The URL fragment only identifies the shoebox item the image was derived from and is ignored by the
+	// cache so the same image gathered for different items is only retrieved, and cached, once
+
+	if b.cache != nil {
+		return b.cache.Fetch(ctx, b.http_client, key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request for %s, %w", key, err)
+	}
+
+	rsp, err := b.http_client.Do(req)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve %s, %w", key, err)
 	}
 
 	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
 		return nil, fmt.Errorf("Failed to retrieve %s, %d %s", key, rsp.StatusCode, rsp.Status)
 	}
 
 	return ioutil.NewReadSeekCloser(rsp.Body)
 }
 
+// newGeneratedReader returns a new `io.ReadSeekCloser` instance for an image identified by 'key' which is generated
+// by its shoebox item resolver.
+func (b *ShoeboxBucket) newGeneratedReader(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+
+	name, ok := shoebox.ResolverNameForKey(key)
+
+	if !ok {
+		return nil, fmt.Errorf("Unable to determine resolver for %s", key)
+	}
+
+	r, exists := b.resolvers[name].(shoebox.RenderResolver)
+
+	if !exists {
+		return nil, fmt.Errorf("Resolver for %s does not generate images", key)
+	}
+
+	return r.Render(ctx, key)
+}
+
 // NewWriter returns an error because this package only implements non-destructive methods of the `aaronland/go-picturebook/bucket.Bucket` interface.
 func (b *ShoeboxBucket) NewWriter(ctx context.Context, key string, opts any) (io.WriteCloser, error) {
 	return nil, fmt.Errorf("Not implemented")
@@ -367,18 +891,65 @@ func (b *ShoeboxBucket) Delete(ctx context.Context, key string) error {
 }
 
 // Attribute returns a new `aaronland/go-picturebook/bucket.Attributes` instance for an object image identified by 'key' in a SFO Museum "shoebox".
+// Attributes are derived from the shoebox item details encoded in 'key' without performing any network requests: `ModTime` is
+// the date the shoebox item was collected and `Size` is the number of pixels (width × height) of the image, as reported by the
+// shoebox item's resolver, if known. If 'key' does not contain any shoebox item details then attributes are derived from a HTTP
+// HEAD request for the image (unless the image is generated by a resolver).
 func (b *ShoeboxBucket) Attributes(ctx context.Context, key string) (*pb_bucket.Attributes, error) {
 
 	if !b.isValidKey(key) {
 		return nil, fmt.Errorf("Invalid key")
 	}
 
-	rsp, err := http.Head(key)
+	attrs := &pb_bucket.Attributes{}
+
+	created, ok := itemCreated(key)
+
+	if ok {
+
+		// The date items which weren't collected in a shoebox were created is unknown so ModTime is left unset
+
+		if created > 0 {
+			attrs.ModTime = time.Unix(created, 0)
+		}
+
+	} else if !shoebox.IsGeneratedKey(key) {
+		return b.attributesWithHead(ctx, key)
+	}
+
+	name, ok := shoebox.ResolverNameForKey(key)
+
+	if ok {
+
+		if r, exists := b.resolvers[name].(shoebox.PixelsResolver); exists {
+
+			if v, known := r.Pixels(key); known {
+				attrs.Size = v
+			}
+		}
+	}
+
+	return attrs, nil
+}
+
+// attributesWithHead returns a new `aaronland/go-picturebook/bucket.Attributes` instance for an image identified by 'key'
+// derived from the Content-Length and Last-Modified headers returned by a HTTP HEAD request.
+func (b *ShoeboxBucket) attributesWithHead(ctx context.Context, key string) (*pb_bucket.Attributes, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, key, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request, %w", err)
+	}
+
+	rsp, err := b.http_client.Do(req)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to execute request, %w", err)
 	}
 
+	defer rsp.Body.Close()
+
 	if rsp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("Request failed: %d %s", rsp.StatusCode, rsp.Status)
 	}
@@ -428,9 +999,39 @@ func (b *ShoeboxBucket) Attributes(ctx context.Context, key string) (*pb_bucket.
 
 // Close completes and terminates any underlying code used by 'b'.
 func (b *ShoeboxBucket) Close() error {
+
+	if b.checkpoint != nil {
+
+		err := b.checkpoint.close()
+
+		if err != nil {
+			return fmt.Errorf("Failed to close checkpoint store, %w", err)
+		}
+	}
+
+	if b.cache != nil {
+		return b.cache.Close()
+	}
+
 	return nil
 }
 
+// itemCreated returns the Unix timestamp when the shoebox item associated with 'key' was collected and a boolean
+// value indicating whether the URL fragment of 'key' could be parsed. The timestamp is zero if the item was not
+// collected in a shoebox.
+func itemCreated(key string) (int64, bool) {
+
+	k, err := keys.Parse(key)
+
+	if err != nil {
+		return 0, false
+	}
+
+	return k.Created, true
+}
+
+// isValidKey returns a boolean value indicating whether 'key' is an image retrieved from one of the valid media hosts
+// or generated by a shoebox item resolver.
 func (b *ShoeboxBucket) isValidKey(key string) bool {
-	return strings.HasPrefix(key, "https://static.sfomuseum.org/media")
+	return b.media_hosts.IsValid(key) || shoebox.IsGeneratedKey(key)
 }
@@ -2,10 +2,20 @@ package bucket
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
+	_ "image/png"
 	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/report"
 )
 
 var token = flag.String("token", "", "...")
@@ -56,3 +66,544 @@ func TestAttributes(t *testing.T) {
 		t.Fatalf("Failed to derive attributes for %s, %v", k, err)
 	}
 }
+
+func TestAttributesFromKey(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket_uri := fmt.Sprintf("shoebox://?token=%s", "TOKEN")
+
+	b, err := NewShoeboxBucket(ctx, bucket_uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create shoebox bucket, %v", err)
+	}
+
+	k := "https://static.sfomuseum.org/media/191/366/340/9/1913663409_MSM9QjCaQmXnyemSonODPufdrayFWc4a_k.jpg#o:123:1511944253:1704182400"
+
+	attrs, err := b.Attributes(ctx, k)
+
+	if err != nil {
+		t.Fatalf("Failed to derive attributes for %s, %v", k, err)
+	}
+
+	if attrs.ModTime.Unix() != 1704182400 {
+		t.Fatalf("Unexpected modtime for %s: %v", k, attrs.ModTime)
+	}
+
+	// Items which weren't collected in a shoebox don't have a modtime
+
+	k = "https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=101;item=101;image=1011;size=k;type=object"
+
+	attrs, err = b.Attributes(ctx, k)
+
+	if err != nil {
+		t.Fatalf("Failed to derive attributes for %s, %v", k, err)
+	}
+
+	if !attrs.ModTime.IsZero() {
+		t.Fatalf("Unexpected modtime for %s: %v", k, attrs.ModTime)
+	}
+}
+
+func TestGatherPicturesWithMockAPI(t *testing.T) {
+
+	ctx := context.Background()
+
+	server := newMockAPIServer(t, mockShoeboxMethods())
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+
+	tests := map[string][]string{
+		"": []string{
+			"https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1011;size=k;type=object",
+			"https://static.sfomuseum.org/media/1012_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1012;size=k;type=object",
+			"https://static.sfomuseum.org/media/1013_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1013;size=k;type=object",
+			"https://static.sfomuseum.org/media/2010_ig_b.jpg#v1;label=ig;id=2010;item=201;created=1704268800;type=instagram",
+			"https://static.sfomuseum.org/media/1021_k_k.jpg#v1;label=o;id=3;item=102;created=1704355200;image=1021;size=k;type=object",
+			"shoebox://card/4.png#v1;label=card;id=4;item=301;created=1704441600;type=flight",
+			"shoebox://card/5.png#v1;label=card;id=5;item=401;created=1704528000;type=gallery",
+			"shoebox://card/6.png#v1;label=card;id=6;item=103;created=1704614400;type=object",
+		},
+		"images=primary&sizes=c&workers=4": []string{
+			"https://static.sfomuseum.org/media/1011_c_c.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1011;size=c;type=object",
+			"https://static.sfomuseum.org/media/2010_ig_b.jpg#v1;label=ig;id=2010;item=201;created=1704268800;type=instagram",
+			"https://static.sfomuseum.org/media/1021_c_c.jpg#v1;label=o;id=3;item=102;created=1704355200;image=1021;size=c;type=object",
+			"shoebox://card/4.png#v1;label=card;id=4;item=301;created=1704441600;type=flight",
+			"shoebox://card/5.png#v1;label=card;id=5;item=401;created=1704528000;type=gallery",
+			"shoebox://card/6.png#v1;label=card;id=6;item=103;created=1704614400;type=object",
+		},
+		"cards=false": []string{
+			"https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1011;size=k;type=object",
+			"https://static.sfomuseum.org/media/1012_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1012;size=k;type=object",
+			"https://static.sfomuseum.org/media/1013_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1013;size=k;type=object",
+			"https://static.sfomuseum.org/media/2010_ig_b.jpg#v1;label=ig;id=2010;item=201;created=1704268800;type=instagram",
+			"https://static.sfomuseum.org/media/1021_k_k.jpg#v1;label=o;id=3;item=102;created=1704355200;image=1021;size=k;type=object",
+		},
+	}
+
+	for str_params, expected := range tests {
+
+		params, _ := url.ParseQuery(str_params)
+
+		case_q := maps.Clone(q)
+
+		for k, v := range params {
+			case_q[k] = v
+		}
+
+		bucket_uri := fmt.Sprintf("shoebox://?%s", case_q.Encode())
+
+		b, err := NewShoeboxBucket(ctx, bucket_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create shoebox bucket, %v", err)
+		}
+
+		uris := make([]string, 0)
+
+		for uri, err := range b.GatherPictures(ctx) {
+
+			if err != nil {
+				t.Fatalf("Failed to gather pictures for '%s', %v", str_params, err)
+			}
+
+			uris = append(uris, uri)
+		}
+
+		if len(uris) != len(expected) {
+			t.Fatalf("Unexpected number of pictures for '%s': %v", str_params, uris)
+		}
+
+		for idx, uri := range uris {
+
+			if uri != expected[idx] {
+				t.Fatalf("Unexpected picture at position %d for '%s': %s", idx, str_params, uri)
+			}
+		}
+	}
+}
+
+func TestMediaHosts(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket_uri := "shoebox://?token=TOKEN&media_host=http://localhost:8080/media"
+
+	b, err := NewShoeboxBucket(ctx, bucket_uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create shoebox bucket, %v", err)
+	}
+
+	_, err = b.Attributes(ctx, "https://static.sfomuseum.org/media/1011_k_k.jpg#o:1:101:1704182400")
+
+	if err == nil {
+		t.Fatalf("Expected default media host to be invalid")
+	}
+
+	_, err = b.Attributes(ctx, "http://localhost:8080/media/1011_k_k.jpg#o:1:101:1704182400")
+
+	if err != nil {
+		t.Fatalf("Expected custom media host to be valid, %v", err)
+	}
+}
+
+func TestGeneratedPictures(t *testing.T) {
+
+	ctx := context.Background()
+
+	server := newMockAPIServer(t, mockShoeboxMethods())
+	defer server.Close()
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+
+	bucket_uri := fmt.Sprintf("shoebox://?%s", q.Encode())
+
+	b, err := NewShoeboxBucket(ctx, bucket_uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create shoebox bucket, %v", err)
+	}
+
+	// Cards are derived from details collected while gathering pictures
+
+	_, err = b.NewReader(ctx, "shoebox://card/4.png", nil)
+
+	if err == nil {
+		t.Fatalf("Expected reader for unknown card to fail")
+	}
+
+	for _, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to gather pictures, %v", err)
+		}
+	}
+
+	// Flights don't have a resolver so they are included as cards
+
+	k := "shoebox://card/4.png#v1;label=card;id=4;item=301;created=1704441600;type=flight"
+
+	// Note the absence of the URL fragment which is removed by go-picturebook
+
+	r, err := b.NewReader(ctx, "shoebox://card/4.png", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create reader for %s, %v", k, err)
+	}
+
+	defer r.Close()
+
+	im, format, err := image.Decode(r)
+
+	if err != nil {
+		t.Fatalf("Failed to decode %s, %v", k, err)
+	}
+
+	if format != "png" {
+		t.Fatalf("Unexpected format for %s: %s", k, format)
+	}
+
+	attrs, err := b.Attributes(ctx, k)
+
+	if err != nil {
+		t.Fatalf("Failed to derive attributes for %s, %v", k, err)
+	}
+
+	bounds := im.Bounds()
+
+	if attrs.Size != int64(bounds.Dx())*int64(bounds.Dy()) {
+		t.Fatalf("Unexpected size for %s: %d", k, attrs.Size)
+	}
+
+	if attrs.ModTime.Unix() != 1704441600 {
+		t.Fatalf("Unexpected modtime for %s: %v", k, attrs.ModTime)
+	}
+
+	_, err = b.NewReader(ctx, "shoebox://o/101.png", nil)
+
+	if err == nil {
+		t.Fatalf("Expected reader for object key to fail")
+	}
+}
+
+func TestDateParameters(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := map[string][2]int64{
+		"year=2024":                       {1704096000, 1735718399},
+		"year=2024&tz=UTC":                {1704067200, 1735689599},
+		"date=2024-01-01/2024-06-30":      {1704096000, 1719817199},
+		"min_date=2024-Q2":                {1711954800, 0},
+		"max_date=2024-03":                {0, 1711954799},
+		"min_date=2024-03&max=1711954799": {1709280000, 1711954799},
+	}
+
+	for str_q, expected := range tests {
+
+		b, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?token=TOKEN&%s", str_q))
+
+		if err != nil {
+			t.Fatalf("Failed to create shoebox bucket for '%s', %v", str_q, err)
+		}
+
+		sb := b.(*ShoeboxBucket)
+
+		if sb.min_date != expected[0] || sb.max_date != expected[1] {
+			t.Fatalf("Unexpected dates for '%s': %d, %d", str_q, sb.min_date, sb.max_date)
+		}
+	}
+
+	invalid := []string{
+		"tz=Mars/Olympus_Mons",
+		"date=2024-13",
+		"min_date=last:90",
+	}
+
+	for _, str_q := range invalid {
+
+		_, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?token=TOKEN&%s", str_q))
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str_q)
+		}
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	methods["sfomuseum.millsfield.instagram.getInfo"] = func(q url.Values) (map[string]any, error) {
+		return nil, fmt.Errorf("Service unavailable")
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	report_path := filepath.Join(t.TempDir(), "report.json")
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("images", "primary")
+	q.Set("report", report_path)
+
+	gather := func(on_error string) ([]string, *report.Report, error) {
+
+		case_q := maps.Clone(q)
+		case_q.Set("on_error", on_error)
+
+		b, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?%s", case_q.Encode()))
+
+		if err != nil {
+			t.Fatalf("Failed to create shoebox bucket for '%s', %v", on_error, err)
+		}
+
+		uris := make([]string, 0)
+		var gather_err error
+
+		for uri, err := range b.GatherPictures(ctx) {
+
+			if err != nil {
+				gather_err = err
+				continue
+			}
+
+			uris = append(uris, uri)
+		}
+
+		rpt, err := report.ReadFile(report_path)
+
+		if err != nil {
+			t.Fatalf("Failed to read report for '%s', %v", on_error, err)
+		}
+
+		return uris, rpt, gather_err
+	}
+
+	// Skip
+
+	uris, rpt, err := gather(ON_ERROR_SKIP)
+
+	if err != nil {
+		t.Fatalf("Unexpected error for skip policy, %v", err)
+	}
+
+	if slices.Contains(uris, "https://static.sfomuseum.org/media/2010_ig_b.jpg#v1;label=ig;id=2010;item=201;created=1704268800;type=instagram") || len(uris) != 5 {
+		t.Fatalf("Unexpected pictures for skip policy: %v", uris)
+	}
+
+	e, exists := rpt.Skipped[report.REASON_HTTP_ERROR]
+
+	if !exists || !slices.Equal(e.ItemIds, []int64{2}) {
+		t.Fatalf("Expected item 2 to be skipped with an HTTP error: %v", rpt.Skipped)
+	}
+
+	e, exists = rpt.Placeholders[report.REASON_UNSUPPORTED_TYPE]
+
+	if !exists || !slices.Equal(e.ItemIds, []int64{4, 5}) {
+		t.Fatalf("Expected items 4 and 5 to be replaced as unsupported types: %v", rpt.Placeholders)
+	}
+
+	if rpt.Items != 6 || rpt.Pictures != 5 {
+		t.Fatalf("Unexpected report totals: %d, %d", rpt.Items, rpt.Pictures)
+	}
+
+	// Placeholder
+
+	uris, rpt, err = gather(ON_ERROR_PLACEHOLDER)
+
+	if err != nil {
+		t.Fatalf("Unexpected error for placeholder policy, %v", err)
+	}
+
+	if len(uris) != 6 || uris[1] != "shoebox://card/2.png#v1;label=card;id=2;item=201;created=1704268800;type=instagram" {
+		t.Fatalf("Unexpected pictures for placeholder policy: %v", uris)
+	}
+
+	e, exists = rpt.Placeholders[report.REASON_HTTP_ERROR]
+
+	if !exists || !slices.Equal(e.ItemIds, []int64{2}) {
+		t.Fatalf("Expected item 2 to be replaced with an HTTP error: %v", rpt.Placeholders)
+	}
+
+	// Fail
+
+	uris, rpt, err = gather(ON_ERROR_FAIL)
+
+	if err == nil {
+		t.Fatalf("Expected fail policy to return an error")
+	}
+
+	if len(uris) != 1 || rpt.Error == "" {
+		t.Fatalf("Unexpected results for fail policy: %v, %s", uris, rpt.Error)
+	}
+
+	_, err = NewShoeboxBucket(ctx, "shoebox://?token=TOKEN&on_error=ignore")
+
+	if err == nil {
+		t.Fatalf("Expected invalid ?on_error= parameter to fail")
+	}
+}
+
+func TestGatherPicturesCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := newMockAPIServer(t, mockShoeboxMethods())
+	defer server.Close()
+
+	checkpoint_path := filepath.Join(t.TempDir(), "checkpoint.json")
+	report_path := filepath.Join(t.TempDir(), "report.json")
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("checkpoint", checkpoint_path)
+	q.Set("report", report_path)
+
+	b, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create shoebox bucket, %v", err)
+	}
+
+	defer b.Close()
+
+	uris := make([]string, 0)
+	var gather_err error
+
+	for uri, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			gather_err = err
+			break
+		}
+
+		uris = append(uris, uri)
+
+		// Cancel after the first picture has been gathered
+		cancel()
+	}
+
+	if !errors.Is(gather_err, context.Canceled) {
+		t.Fatalf("Expected gathering pictures to be cancelled, %v", gather_err)
+	}
+
+	if len(uris) == 0 || len(uris) > 3 {
+		t.Fatalf("Unexpected pictures gathered before cancelling: %v", uris)
+	}
+
+	rpt, err := report.ReadFile(report_path)
+
+	if err != nil {
+		t.Fatalf("Failed to read report, %v", err)
+	}
+
+	if rpt.Error == "" {
+		t.Fatalf("Expected report to record cancellation")
+	}
+
+	_, err = os.Stat(checkpoint_path)
+
+	if err != nil {
+		t.Fatalf("Expected checkpoint to be written after cancelling, %v", err)
+	}
+}
+
+func TestInvalidImageRecord(t *testing.T) {
+
+	ctx := context.Background()
+
+	methods := mockShoeboxMethods()
+
+	get_images := methods["sfomuseum.collection.objects.getImages"]
+
+	methods["sfomuseum.collection.objects.getImages"] = func(q url.Values) (map[string]any, error) {
+
+		body, err := get_images(q)
+
+		if err != nil || q.Get("object_id") != "101" {
+			return body, err
+		}
+
+		// The first image for object 101 is missing its URI template
+
+		images := slices.Clone(body["images"].([]any))
+		im := maps.Clone(images[0].(map[string]any))
+		delete(im, "media:uri_template")
+		images[0] = im
+
+		body["images"] = images
+		return body, nil
+	}
+
+	server := newMockAPIServer(t, methods)
+	defer server.Close()
+
+	report_path := filepath.Join(t.TempDir(), "report.json")
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+	q.Set("api", server.URL)
+	q.Set("report", report_path)
+	q.Set("limit", "1")
+
+	b, err := NewShoeboxBucket(ctx, fmt.Sprintf("shoebox://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create shoebox bucket, %v", err)
+	}
+
+	uris := make([]string, 0)
+
+	for uri, err := range b.GatherPictures(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to gather pictures, %v", err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	expected := []string{
+		"https://static.sfomuseum.org/media/1012_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1012;size=k;type=object",
+		"https://static.sfomuseum.org/media/1013_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1013;size=k;type=object",
+	}
+
+	if !slices.Equal(uris, expected) {
+		t.Fatalf("Unexpected pictures: %v", uris)
+	}
+
+	rpt, err := report.ReadFile(report_path)
+
+	if err != nil {
+		t.Fatalf("Failed to read report, %v", err)
+	}
+
+	e, exists := rpt.Skipped[report.REASON_MISSING_TEMPLATE]
+
+	if !exists || !slices.Equal(e.ItemIds, []int64{1}) {
+		t.Fatalf("Expected item 1 to be skipped (in part) with a missing template: %v", rpt.Skipped)
+	}
+}
+
+func TestSchemes(t *testing.T) {
+
+	expected := []string{"api", "collection", "depicts", "exhibition", "instagram", "objects", "shoebox"}
+
+	if !slices.Equal(Schemes(), expected) {
+		t.Fatalf("Unexpected schemes, %v", Schemes())
+	}
+}
@@ -0,0 +1,87 @@
+package bucket
+
+import (
+	"context"
+	"iter"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+// shoeboxItemResult is the list of picture URIs derived from a shoebox item and the reasons, if any, why some or all
+// of its pictures were skipped or replaced by a placeholder.
+type shoeboxItemResult struct {
+	// The shoebox item being resolved.
+	item *response.ShoeboxListItem
+	// The list of picture URIs derived from the shoebox item.
+	uris []string
+	// The perceptual hashes for the pictures derived from the shoebox item, keyed by URI. Only populated when the `DEDUPE_PHASH` mode is used.
+	phashes map[string]uint64
+	// The reasons why some or all of the pictures for the shoebox item were skipped.
+	skipped []string
+	// The reason why the shoebox item was replaced by a placeholder. If empty the item was not replaced.
+	placeholder string
+	// An error that should cause gathering pictures to stop (when the `ON_ERROR_FAIL` policy is used).
+	err error
+}
+
+// resolveOrdered invokes 'fn' for each element in 'items' using up to 'workers' concurrent goroutines and
+// returns an iterator which yields the results in the same order as 'items'. Results are yielded as soon
+// as they (and all the results preceding them) are available.
+func resolveOrdered[T any, R any](ctx context.Context, workers int, items []T, fn func(context.Context, T) R) iter.Seq[R] {
+
+	return func(yield func(R) bool) {
+
+		if workers < 1 {
+			workers = 1
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// Each result channel is buffered so that workers never block
+		// waiting for the results preceding them to be consumed.
+
+		pending := make([]chan R, len(items))
+
+		for idx := range items {
+			pending[idx] = make(chan R, 1)
+		}
+
+		throttle := make(chan bool, workers)
+
+		go func() {
+
+			for idx, item := range items {
+
+				select {
+				case <-ctx.Done():
+					return
+				case throttle <- true:
+					// pass
+				}
+
+				go func(idx int, item T) {
+
+					defer func() {
+						<-throttle
+					}()
+
+					pending[idx] <- fn(ctx, item)
+				}(idx, item)
+			}
+		}()
+
+		for idx := range items {
+
+			select {
+			case <-ctx.Done():
+				return
+			case r := <-pending[idx]:
+
+				if !yield(r) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package bucket
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolveOrdered(t *testing.T) {
+
+	ctx := context.Background()
+
+	items := make([]int, 100)
+
+	for idx := range items {
+		items[idx] = idx
+	}
+
+	var running int32
+	var max_running int32
+
+	fn := func(ctx context.Context, i int) int {
+
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			m := atomic.LoadInt32(&max_running)
+
+			if n <= m || atomic.CompareAndSwapInt32(&max_running, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		return i * 2
+	}
+
+	expected := 0
+
+	for r := range resolveOrdered(ctx, 8, items, fn) {
+
+		if r != expected*2 {
+			t.Fatalf("Unexpected result at offset %d: %d", expected, r)
+		}
+
+		expected += 1
+	}
+
+	if expected != len(items) {
+		t.Fatalf("Unexpected number of results: %d", expected)
+	}
+
+	if max_running > 8 {
+		t.Fatalf("Exceeded maximum number of workers: %d", max_running)
+	}
+
+	count := 0
+
+	for range resolveOrdered(ctx, 4, items, fn) {
+
+		count += 1
+
+		if count == 10 {
+			break
+		}
+	}
+}
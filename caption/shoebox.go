@@ -2,30 +2,23 @@ package caption
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"net/url"
-	"path/filepath"
-	"strconv"
-	"strings"
-	"time"
 
 	pb_bucket "github.com/aaronland/go-picturebook/bucket"
 	pb_caption "github.com/aaronland/go-picturebook/caption"
 	"github.com/dgraph-io/ristretto/v2"
-	"github.com/mitchellh/go-wordwrap"
-	"github.com/rainycape/unidecode"
-	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
-	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/media"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/shoebox"
 )
 
 // ShoeboxCaption implements the `aaronland/go-picturebook/caption.Caption` interface for use with object images in a SFO Museum "shoebox".
 type ShoeboxCaption struct {
 	pb_caption.Caption
-	api_client client.Client
-	cache      *ristretto.Cache[string, string]
+	resolvers   map[string]shoebox.Resolver
+	media_hosts *media.Hosts
+	cache       *ristretto.Cache[string, string]
 }
 
 func init() {
@@ -39,6 +32,16 @@ func init() {
 }
 
 // NewShoeboxCaption returns a new `ShoeboxCaption` instance implementing the `aaronland/go-picturebook/caption.Caption` interface for use with object images in a SFO Museum "shoebox".
+// 'uri' is expected to take the form of:
+//
+//	shoebox://?{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?token={TOKEN}` A valid SFO Museum API access token.
+// - `?api={URI}` An optional SFO Museum API endpoint. See `api.NewClientWithQuery` for details.
+// - `?insecure={BOOLEAN}` An optional boolean flag signaling that TLS verification for the API endpoint should be skipped.
+// - `?media_host={HOST}` Zero or more hosts or URI prefixes for the images being captioned. See `media.NewHosts` for details.
+// - Any other parameters for the shoebox item resolvers registered with the `shoebox` package.
 func NewShoeboxCaption(ctx context.Context, uri string) (pb_caption.Caption, error) {
 
 	u, err := url.Parse(uri)
@@ -48,14 +51,17 @@ func NewShoeboxCaption(ctx context.Context, uri string) (pb_caption.Caption, err
 	}
 
 	q := u.Query()
-	token := q.Get("token")
 
-	client_uri := fmt.Sprintf("oauth2://?access_token=%s", token)
+	resolvers, err := shoebox.NewResolvers(ctx, q)
 
-	api_client, err := client.NewClient(ctx, client_uri)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create shoebox resolvers, %w", err)
+	}
+
+	media_hosts, err := media.NewHosts(q)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create new client, %w", err)
+		return nil, fmt.Errorf("Failed to derive media hosts, %w", err)
 	}
 
 	cache, err := ristretto.NewCache(&ristretto.Config[string, string]{
@@ -69,8 +75,9 @@ func NewShoeboxCaption(ctx context.Context, uri string) (pb_caption.Caption, err
 	}
 
 	c := &ShoeboxCaption{
-		cache:      cache,
-		api_client: api_client,
+		cache:       cache,
+		resolvers:   resolvers,
+		media_hosts: media_hosts,
 	}
 
 	return c, nil
@@ -79,147 +86,45 @@ func NewShoeboxCaption(ctx context.Context, uri string) (pb_caption.Caption, err
 // Text returns the caption text for object image identified by 'key' in 'b'.
 func (c *ShoeboxCaption) Text(ctx context.Context, b pb_bucket.Bucket, key string) (string, error) {
 
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
 	logger := slog.Default()
 	logger = logger.With("key", key)
 
+	if !c.media_hosts.IsValid(key) && !shoebox.IsGeneratedKey(key) {
+		logger.Error("Invalid key")
+		return "", fmt.Errorf("Invalid key")
+	}
+
 	str_caption, found := c.cache.Get(key)
 
 	if found {
 		return str_caption, nil
 	}
 
-	base := filepath.Base(key)
-	parts := strings.Split(base, "#")
+	name, ok := shoebox.ResolverNameForKey(key)
 
-	if len(parts) != 2 {
-		logger.Error("Invalid key")
-		return "", fmt.Errorf("Invalid key")
+	if !ok {
+		logger.Error("Unhandled or unsupported key")
+		return "", fmt.Errorf("Unhandled or unsupported key")
 	}
 
-	// {LABEL}:{ID}:{ITEM_ID}:{ITEM_CREATED}
-
-	fragment := strings.Split(parts[1], ":")
+	r, exists := c.resolvers[name]
 
-	if len(fragment) != 4 {
-		logger.Error("Invalid fragment")
-		return "", fmt.Errorf("Invalid format")
+	if !exists {
+		logger.Error("Missing resolver", "name", name)
+		return "", fmt.Errorf("Missing resolver for %s", name)
 	}
 
-	/*
-
-		str_item_id := fragment[2]
-
-		item_id, err := strconv.ParseInt(str_item_id, 10, 64)
-
-		if err != nil {
-			return "", err
-		}
-
-	*/
-
-	str_item_created := fragment[3]
-
-	item_created, err := strconv.ParseInt(str_item_created, 10, 64)
+	str_caption, err := r.Caption(ctx, key)
 
 	if err != nil {
+		logger.Error("Failed to derive caption", "resolver", name, "error", err)
 		return "", err
 	}
 
-	collected_t := time.Unix(item_created, 0)
-
-	switch fragment[0] {
-	case "o":
-
-		// Objects
-		// https://api.sfomuseum.org/methods/sfomuseum.collection.images.getCaption
-
-		// Please use a regexp...
-		parts := strings.Split(base, "_")
-		image_id := parts[0]
-
-		logger = logger.With("image", image_id)
-
-		args := &url.Values{}
-		args.Set("method", "sfomuseum.collection.images.getCaption")
-		args.Set("image_id", image_id)
-
-		r, err := c.api_client.ExecuteMethod(ctx, http.MethodGet, args)
-
-		if err != nil {
-			logger.Error("Failed to get caption", "error", err)
-			return "", err
-		}
-
-		var caption_rsp *response.ImageCaptionResponse
-
-		dec := json.NewDecoder(r)
-		err = dec.Decode(&caption_rsp)
-
-		if err != nil {
-			logger.Error("Failed to decode caption", "error", err)
-			return "", err
-		}
-
-		str_caption = caption_rsp.Caption.String()
-		str_caption = fmt.Sprintf("%s\nCollected on %s", str_caption, collected_t.Format("January 02, 2006"))
-
-		c.cache.Set(key, str_caption, 1)
-		return str_caption, nil
-
-	case "ig":
-
-		// Instagram posts
-		// All of the fragment info is assigned in bucket/shoebox.go
-
-		post_id := fragment[1]
-		logger = logger.With("post id", post_id)
-
-		ig_args := &url.Values{}
-		ig_args.Set("method", "sfomuseum.millsfield.instagram.getInfo")
-		ig_args.Set("post_id", post_id)
-
-		ig_rsp, err := c.api_client.ExecuteMethod(ctx, http.MethodGet, ig_args)
-
-		if err != nil {
-			logger.Error("Failed to get info for IG post", "error", err)
-			return "", fmt.Errorf("Failed to execute sfomuseum.millsfield.instagram.getInfo method, %w", err)
-		}
-
-		defer ig_rsp.Close()
-		var ig_post_rsp *response.InstagramPostResponse
-
-		dec := json.NewDecoder(ig_rsp)
-		err = dec.Decode(&ig_post_rsp)
-
-		if err != nil {
-			logger.Error("Failed to unmarshal IG post", "error", err)
-			return "", fmt.Errorf("Failed to unmarshal IG post response, %w", err)
-		}
-
-		ig_post := ig_post_rsp.Post
-		post_t := time.Unix(ig_post.Taken, 0)
-
-		// This shouldn't be necessary (in an ideal world) but the
-		// aaronland/go-picturebook package uses HTMLBasicNew() for
-		// adding text and it has... issues.
-		ig_body := unidecode.Unidecode(ig_post.Caption.Excerpt)
-
-		// Maybe make this value configurable in the shoebox:// caption URI?
-		ig_body = wordwrap.WrapString(ig_body, 145)
-
-		text := []string{
-			fmt.Sprintf(`"%s"`, ig_body),
-			fmt.Sprintf("This was posted to the SFO Museum Instagram account on %s", post_t.Format("January 02, 2006")),
-			"",
-			fmt.Sprintf("https://millsfield.sfomuseum.org/instagram/%s", post_id),
-			fmt.Sprintf("Collected on %s", collected_t.Format("January 02, 2006")),
-		}
-
-		str_text := strings.Join(text, "\n")
-		return str_text, nil
-
-	default:
-		logger.Error("Unhandled or unsupported fragment type", "fragment", parts[1])
-		return "", fmt.Errorf("Unhandled or unsupported fragment, %s", fragment[0])
-	}
+	c.cache.Set(key, str_caption, 1)
+	return str_caption, nil
 }
@@ -3,20 +3,35 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"maps"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	_ "github.com/sfomuseum/go-picturebook-sfomuseum/bucket"
 	_ "github.com/sfomuseum/go-picturebook-sfomuseum/caption"
+	_ "github.com/sfomuseum/go-picturebook-sfomuseum/sort"
 	_ "gocloud.dev/blob/fileblob"
 
+	pb "github.com/aaronland/go-picturebook"
 	"github.com/aaronland/go-picturebook/app/picturebook"
+	pb_bucket "github.com/aaronland/go-picturebook/bucket"
 	"github.com/sfomuseum/go-flags/flagset"
 	"github.com/sfomuseum/go-flags/multi"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/bucket"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/dates"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/report"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/transport"
 )
 
 // String label defining the orientation of picturebook PDF files. Valid orientations are: 'P' and 'L' for portrait and landscape mode respectively.
@@ -106,6 +121,108 @@ var access_token string
 // Limit shoebox items to those collected during a specific year.
 var year int
 
+// Limit shoebox items to those collected on or after the start of this date.
+var min_date string
+
+// Limit shoebox items to those collected on or before the end of this date.
+var max_date string
+
+// Limit shoebox items to those collected during a specific month.
+var month string
+
+// The timezone used to derive the boundaries of dates and date ranges.
+var tz string
+
+// An optional SFO Museum API endpoint to use instead of the default endpoint.
+var api_endpoint string
+
+// Zero or more hosts or URI prefixes from which images may be retrieved.
+var media_hosts multi.MultiString
+
+// Boolean flag to signal that TLS verification for the API endpoint should be skipped.
+var insecure bool
+
+// The maximum number of attempts (including the first) for API and image requests which fail with a transient error.
+var max_attempts int
+
+// The delay before retrying a failed request for the first time.
+var backoff time.Duration
+
+// The maximum delay before retrying a failed request.
+var max_backoff time.Duration
+
+// The maximum number of API and image requests per second for each host.
+var rate_limit float64
+
+// The maximum time to establish a connection for API and image requests.
+var connect_timeout time.Duration
+
+// The maximum time to wait for the response headers or between reads of the response body for API and image requests.
+var read_timeout time.Duration
+
+// The maximum time for each attempt of an API or image request.
+var timeout time.Duration
+
+// An optional HTTP(S) proxy to send API and image requests through.
+var proxy string
+
+// An optional path to a file containing additional PEM-encoded certificate authorities to trust.
+var ca_bundle string
+
+// The User-Agent header sent with API and image requests.
+var user_agent string
+
+// An ordered, comma-separated list of image size labels to consider when selecting object images.
+var image_sizes string
+
+// A valid gocloud.dev/blob.Bucket URI where images will be cached between runs.
+var cache_uri string
+
+// Boolean flag to signal that cached images should be revalidated using conditional HTTP requests.
+var cache_revalidate bool
+
+// The maximum number of shoebox items to resolve concurrently.
+var workers int
+
+// Which images to include for each object. Valid options are "all", "primary" or "first:{N}".
+var images string
+
+// Boolean flag to signal that the smallest image size large enough to be printed at the -dpi resolution should be selected.
+var match_dpi bool
+
+// Boolean flag to signal that shoebox items without any usable images should be skipped rather than included as generated "cards".
+var no_cards bool
+
+// The order in which shoebox items are listed. Valid options are "asc" and "desc".
+var order string
+
+// Zero or more numeric shoebox item status codes to include.
+var status multi.MultiString
+
+// The maximum number of shoebox items to include.
+var limit int
+
+// The number of shoebox items to randomly sample.
+var sample int
+
+// An optional seed for randomly sampling shoebox items.
+var seed string
+
+// How to handle shoebox items which can not be resolved. Valid options are "fail", "skip" or "placeholder".
+var on_error string
+
+// An optional path where a JSON-encoded summary of the shoebox items which were skipped or replaced by placeholders will be written.
+var report_path string
+
+// An optional path, or gocloud.dev/blob.Bucket URI, where progress is recorded so that an interrupted picturebook can be resumed.
+var checkpoint_uri string
+
+// An optional mode for suppressing duplicate pictures. Valid options are "url" or "phash".
+var dedupe string
+
+// An optional aaronland/go-picturebook/bucket.Bucket URI to gather pictures from instead of a shoebox.
+var source_bucket_uri string
+
 func main() {
 
 	ctx := context.Background()
@@ -113,6 +230,19 @@ func main() {
 	fs := flagset.NewFlagSet("picturebook")
 
 	fs.StringVar(&access_token, "access-token", "", "A valid SFO Museum API access token to retrieve your shoebox items (must have \"read\" permissions).")
+	fs.StringVar(&api_endpoint, "api-endpoint", "", "An optional SFO Museum API endpoint to use instead of the default https://api.sfomuseum.org/rest endpoint (for example a staging server or a local mock server). Plain-text http:// endpoints are only supported for loopback hosts.")
+	fs.Var(&media_hosts, "media-host", "Zero or more hosts or URI prefixes from which images may be retrieved. If empty the default https://static.sfomuseum.org/media prefix is used.")
+	fs.BoolVar(&insecure, "insecure", false, "Skip TLS verification for the API endpoint. This is principally for testing against local (mock) API servers.")
+	fs.IntVar(&max_attempts, "max-attempts", transport.DEFAULT_MAX_ATTEMPTS, "The maximum number of attempts (including the first) for API and image requests which fail with a transient error (a network error or a 429, 502, 503 or 504 HTTP status code).")
+	fs.DurationVar(&backoff, "backoff", transport.DEFAULT_BACKOFF, "The delay before retrying a failed API or image request for the first time. Subsequent delays are doubled (with jitter).")
+	fs.DurationVar(&max_backoff, "max-backoff", transport.DEFAULT_MAX_BACKOFF, "The maximum delay before retrying a failed API or image request, including delays requested by the server with a Retry-After header.")
+	fs.Float64Var(&rate_limit, "rate-limit", 0, "The maximum number of API and image requests per second for each host. If 0 there is no limit.")
+	fs.DurationVar(&connect_timeout, "connect-timeout", transport.DEFAULT_CONNECT_TIMEOUT, "The maximum time to establish a connection (including the TLS handshake) for API and image requests. If 0 there is no limit.")
+	fs.DurationVar(&read_timeout, "read-timeout", transport.DEFAULT_READ_TIMEOUT, "The maximum time to wait for the response headers, or between reads of the response body, for API and image requests. If 0 there is no limit.")
+	fs.DurationVar(&timeout, "timeout", transport.DEFAULT_TIMEOUT, "The maximum time for each attempt of an API or image request, including reading the response body. If 0 there is no limit.")
+	fs.StringVar(&proxy, "proxy", "", "An optional HTTP(S) proxy URL to send API and image requests through. If empty the proxy is derived from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.")
+	fs.StringVar(&ca_bundle, "ca-bundle", "", "An optional path to a file containing one or more PEM-encoded certificate authorities (for example those of a TLS-inspecting proxy) to trust in addition to the system certificate authorities.")
+	fs.StringVar(&user_agent, "user-agent", transport.DEFAULT_USER_AGENT, "The User-Agent header sent with API and image requests.")
 	fs.StringVar(&orientation, "orientation", "P", "The orientation of your picturebook. Valid orientations are: 'P' and 'L' for portrait and landscape mode respectively.")
 	fs.StringVar(&size, "size", "letter", `A common paper size to use for the size of your picturebook. Valid sizes are: "a3", "a4", "a5", "letter", "legal", or "tabloid".`)
 	fs.Float64Var(&width, "width", 0.0, "A custom height to use as the size of your picturebook. Units are defined in inches by default. This flag overrides the -size flag when used in combination with the -height flag.")
@@ -134,6 +264,28 @@ func main() {
 	fs.StringVar(&filename, "filename", "shoebox.pdf", "The filename (path) for your picturebook.")
 
 	fs.IntVar(&year, "year", 0, "Limit shoebox items to those collected during a specific year.")
+	fs.StringVar(&min_date, "min-date", "", `Limit shoebox items to those collected on or after the start of this date. Valid dates are years ("2024"), quarters ("2024-Q1"), months ("2024-03"), days ("2024-03-15") or relative windows ("last:90d").`)
+	fs.StringVar(&max_date, "max-date", "", `Limit shoebox items to those collected on or before the end of this date. Valid dates are years ("2024"), quarters ("2024-Q1"), months ("2024-03") or days ("2024-03-15").`)
+	fs.StringVar(&month, "month", "", `Limit shoebox items to those collected during a specific month, in the form of YYYY-MM ("2024-03").`)
+	fs.StringVar(&tz, "tz", dates.DEFAULT_TIMEZONE, "The timezone used to derive the boundaries of the -year, -month, -min-date and -max-date flags.")
+
+	fs.StringVar(&cache_uri, "cache-uri", "", "An optional gocloud.dev/blob.Bucket URI (for example file:///usr/local/picturebook/cache) where images will be cached between runs. Once cached, rebuilding a picturebook will not retrieve those images again.")
+	fs.BoolVar(&cache_revalidate, "cache-revalidate", false, "If true cached images will be revalidated using conditional HTTP requests.")
+	fs.StringVar(&checkpoint_uri, "checkpoint", "", "An optional path to a local file (or a gocloud.dev/blob.Bucket URI) where progress is recorded while your shoebox items are being gathered. If creating your picturebook is interrupted, running the same command again will resume from the last page of shoebox items processed and reuse the items which were already resolved (unless they have been modified since). The checkpoint is removed once your picturebook has been created.")
+	fs.StringVar(&dedupe, "dedupe", "", `An optional mode for suppressing duplicate pictures. Valid options are "url" (pictures with the same URL) or "phash" (pictures with the same URL or similar perceptual hashes). The first occurrence of a picture is kept and any duplicates are listed in the summary printed once your picturebook has been created. Computing perceptual hashes retrieves images while gathering pictures so "phash" should be used with the -cache-uri flag.`)
+	fs.StringVar(&order, "order", "asc", `The order in which shoebox items are added to your picturebook. Valid options are "asc" (oldest first) or "desc" (newest first).`)
+	fs.Var(&status, "status", "Zero or more numeric shoebox item status codes. If present only items with one of these status codes will be included.")
+	fs.IntVar(&limit, "limit", 0, "The maximum number of shoebox items to include. This is useful for creating quick proofs of a picturebook.")
+	fs.IntVar(&sample, "sample", 0, "Include a random sample of this many shoebox items.")
+	fs.StringVar(&seed, "seed", "", "An optional (numeric) seed for the -sample flag. Using the same seed will produce the same sample.")
+	fs.IntVar(&workers, "workers", 1, "The maximum number of shoebox items to resolve concurrently. Images are still added to your picturebook in the order they were collected.")
+	fs.StringVar(&images, "images", "all", `Which images to include for each object. Valid options are "all", "primary" or "first:{N}".`)
+	fs.StringVar(&image_sizes, "image-sizes", "", `An ordered, comma-separated list of image size labels to consider when selecting object images. If empty the default list ("o,k,b,c") is used.`)
+	fs.BoolVar(&match_dpi, "match-dpi", false, "Select the smallest available size of each object image that is large enough to be printed at the -dpi resolution, rather than the largest available size. This can significantly reduce the time it takes to create a picturebook and the size of the final document.")
+	fs.BoolVar(&no_cards, "no-cards", false, `Skip shoebox items that don't have any usable images (for example items whose type is not supported or objects that haven't been photographed yet) rather than including them as generated "card" pages.`)
+
+	fs.StringVar(&on_error, "on-error", "skip", `How to handle shoebox items (or their images) which can not be resolved. Valid options are "fail" (stop creating your picturebook), "skip" (leave the item out) or "placeholder" (replace the item with a generated "card" page).`)
+	fs.StringVar(&report_path, "report", "", "An optional path where a JSON-encoded summary of the shoebox items which were skipped or replaced by placeholders will be written. A summary is always printed once your picturebook has been created.")
 
 	fs.BoolVar(&verbose, "verbose", false, "Display verbose output as the picturebook is created.")
 
@@ -142,8 +294,9 @@ func main() {
 
 	// fs.Var(&caption_uris, "caption", desc_captions)
 	// fs.StringVar(&text_uri, "text", "", desc_texts)
-	// fs.StringVar(&sort_uri, "sort", "", desc_sorters)
+	fs.StringVar(&sort_uri, "sort", "", `A valid aaronland/go-picturebook/sort.Sorter URI. The "shoebox://" sorter will sort images by the date their shoebox items were collected while keeping all the images for a given item together.`)
 
+	fs.StringVar(&source_bucket_uri, "source", "", `An optional aaronland/go-picturebook/bucket.Bucket URI to gather pictures from instead of your shoebox. For example "collection://?medium=postcard&decade=1950s" to create a picturebook of the objects in the SFO Museum Aviation Collection matching a search query. For the buckets defined by this package (api://, collection://, depicts://, exhibition://, instagram://, objects:// and shoebox://) parameters derived from other flags (for example -access-token) are added to the URI, prefixed with shoebox_ for api:// URIs, unless it already defines them. Any other URI is used as-is.`)
 	fs.StringVar(&target_uri, "target-uri", "", "A valid aaronland/go-picturebook/bucket.Bucket URI for where the final picturebook file will be written to.")
 	// fs.StringVar(&tmpfile_uri, "tmpfile-uri", "", "...")
 
@@ -151,20 +304,166 @@ func main() {
 
 	flagset.Parse(fs)
 
-	source_q := url.Values{}
-	source_q.Set("token", access_token)
+	// Cancel everything (cleanly) if the application is interrupted. Once cancelled the default signal
+	// handling is restored so that a second interrupt will terminate the application immediately.
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Parameters shared by the shoebox:// bucket and caption URIs
+
+	api_q := url.Values{}
+	api_q.Set("token", access_token)
+
+	if api_endpoint != "" {
+		api_q.Set("api", api_endpoint)
+	}
+
+	for _, h := range media_hosts {
+		api_q.Add("media_host", h)
+	}
+
+	api_q.Set("max_attempts", strconv.Itoa(max_attempts))
+	api_q.Set("backoff", backoff.String())
+	api_q.Set("max_backoff", max_backoff.String())
+
+	if rate_limit > 0 {
+		api_q.Set("rate_limit", strconv.FormatFloat(rate_limit, 'f', -1, 64))
+	}
+
+	api_q.Set("connect_timeout", connect_timeout.String())
+	api_q.Set("read_timeout", read_timeout.String())
+	api_q.Set("timeout", timeout.String())
+
+	if proxy != "" {
+		api_q.Set("proxy", proxy)
+	}
+
+	if ca_bundle != "" {
+		api_q.Set("ca_bundle", ca_bundle)
+	}
+
+	if user_agent != "" {
+		api_q.Set("user_agent", user_agent)
+	}
+
+	// API clients perform requests using http.DefaultTransport so install a transport which retries (and rate limits, etc.)
+	// API requests according to the flags above. Skipping TLS verification for the API endpoint is handled by the transport
+	// rather than the clients, which would otherwise bypass it.
+
+	transport_q := maps.Clone(api_q)
+
+	if insecure {
+		transport_q.Set("insecure", strconv.FormatBool(insecure))
+	}
+
+	_, err := api.InstallTransportWithQuery(transport_q)
+
+	if err != nil {
+		log.Fatalf("Failed to install API transport, %v", err)
+	}
+
+	source_q := maps.Clone(api_q)
 
 	if year > 0 {
 		source_q.Set("year", strconv.Itoa(year))
 	}
 
-	source_u := url.URL{}
-	source_u.Scheme = "shoebox"
-	source_u.RawQuery = source_q.Encode()
+	if month != "" {
 
-	source_uri := source_u.String() // fmt.Sprintf("shoebox://?token=%s", access_token)
-	tmpfile_uri := ""
-	caption_uri := fmt.Sprintf("shoebox://?token=%s", access_token)
+		// -month is passed through as a ?date= parameter which accepts any date range so make sure it is actually a month
+
+		_, err := time.Parse("2006-01", month)
+
+		if err != nil {
+			log.Fatalf("Invalid -month flag '%s', must be a month in the form of YYYY-MM (for example \"2024-03\")", month)
+		}
+
+		source_q.Set("date", month)
+	}
+
+	if min_date != "" {
+		source_q.Set("min_date", min_date)
+	}
+
+	if max_date != "" {
+		source_q.Set("max_date", max_date)
+	}
+
+	if tz != "" {
+		source_q.Set("tz", tz)
+	}
+
+	if cache_uri != "" {
+		source_q.Set("cache", cache_uri)
+		source_q.Set("cache_revalidate", strconv.FormatBool(cache_revalidate))
+	}
+
+	if dedupe != "" {
+		source_q.Set("dedupe", dedupe)
+	}
+
+	if checkpoint_uri != "" {
+		source_q.Set("checkpoint", checkpoint_uri)
+	}
+
+	if workers > 1 {
+		source_q.Set("workers", strconv.Itoa(workers))
+	}
+
+	if images != "" {
+		source_q.Set("images", images)
+	}
+
+	if image_sizes != "" {
+		source_q.Set("sizes", image_sizes)
+	}
+
+	if order != "" {
+		source_q.Set("sort", order)
+	}
+
+	for _, v := range status {
+		source_q.Add("status", v)
+	}
+
+	if limit > 0 {
+		source_q.Set("limit", strconv.Itoa(limit))
+	}
+
+	if sample > 0 {
+		source_q.Set("sample", strconv.Itoa(sample))
+
+		if seed != "" {
+			source_q.Set("seed", seed)
+		}
+	}
+
+	if no_cards {
+		source_q.Set("cards", strconv.FormatBool(false))
+	}
+
+	if on_error != "" {
+		source_q.Set("on_error", on_error)
+	}
+
+	if match_dpi {
+
+		page_w, page_h, err := printableArea(ctx)
+
+		if err != nil {
+			log.Fatalf("Failed to derive printable area, %v", err)
+		}
+
+		source_q.Set("target_dpi", strconv.FormatFloat(dpi, 'f', -1, 64))
+		source_q.Set("page_width", strconv.FormatFloat(page_w, 'f', 4, 64))
+		source_q.Set("page_height", strconv.FormatFloat(page_h, 'f', 4, 64))
+	}
 
 	if target_uri == "" {
 
@@ -178,6 +477,92 @@ func main() {
 		target_uri = dir
 	}
 
+	// Temporary files (the images processed by the picturebook application and the shoebox report, unless
+	// a -report flag was specified) are written to a temporary directory which is removed when the application
+	// exits, including when it is interrupted. Note that log.Fatal does not run deferred functions so 'cleanup'
+	// needs to be called explicitly before exiting with an error.
+
+	tmp_dir, err := os.MkdirTemp("", "picturebook-")
+
+	if err != nil {
+		log.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	cleanup := func() {
+		os.RemoveAll(tmp_dir)
+	}
+
+	defer cleanup()
+
+	tmpfile_u := url.URL{}
+	tmpfile_u.Scheme = "file"
+	tmpfile_u.Path = tmp_dir
+
+	tmpfile_uri := tmpfile_u.String()
+
+	// The shoebox:// bucket writes its report to a file since it is created (and closed) by the
+	// picturebook application. If no -report flag was specified use a temporary file.
+
+	if report_path == "" {
+		report_path = filepath.Join(tmp_dir, "report.json")
+	}
+
+	source_q.Set("report", report_path)
+
+	source_u := &url.URL{}
+	source_u.Scheme = "shoebox"
+
+	if source_bucket_uri != "" {
+
+		u, err := url.Parse(source_bucket_uri)
+
+		if err != nil {
+			cleanup()
+			log.Fatalf("Failed to parse -source flag, %v", err)
+		}
+
+		if slices.Contains(bucket.Schemes(), u.Scheme) {
+
+			// The api:// bucket passes any parameters which aren't prefixed as arguments to its API method
+
+			if u.Scheme == "api" {
+
+				api_source_q := url.Values{}
+
+				for k, v := range source_q {
+					api_source_q[bucket.API_SHOEBOX_PREFIX+k] = v
+				}
+
+				source_q = api_source_q
+			}
+
+			// Parameters defined by the -source flag take precedence over those derived from other flags
+
+			for k, v := range u.Query() {
+				source_q[k] = v
+			}
+
+		} else {
+
+			// Buckets which are not defined by this package don't know about (and may reject) the parameters
+			// derived from other flags so the -source URI is used as-is
+
+			source_q = u.Query()
+		}
+
+		source_u = u
+	}
+
+	source_u.RawQuery = source_q.Encode()
+
+	source_uri := source_u.String() // fmt.Sprintf("shoebox://?token=%s", access_token)
+
+	caption_u := url.URL{}
+	caption_u.Scheme = "shoebox"
+	caption_u.RawQuery = api_q.Encode()
+
+	caption_uri := caption_u.String()
+
 	monitor_uri := "progressbar://"
 
 	run_opts := &picturebook.RunOptions{
@@ -207,7 +592,7 @@ func main() {
 			caption_uri,
 		},
 		TextURI: "",
-		SortURI: "",
+		SortURI: sort_uri,
 
 		Sources:            []string{"."},
 		Filename:           filename,
@@ -215,10 +600,144 @@ func main() {
 		Verbose:            verbose,
 	}
 
-	err := picturebook.RunWithOptions(ctx, run_opts)
+	err = picturebook.RunWithOptions(ctx, run_opts)
+
+	if ctx.Err() != nil {
+
+		cleanup()
+
+		if checkpoint_uri != "" {
+			log.Fatal("Interrupted, your picturebook was not created. Run the same command again to resume from the -checkpoint.")
+		}
+
+		log.Fatal("Interrupted, your picturebook was not created.")
+	}
 
 	if err != nil {
+		cleanup()
 		log.Fatalf("Failed to run picturebook application, %v", err)
 	}
 
+	rpt, err := report.ReadFile(report_path)
+
+	// Buckets which are not defined by this package don't write a report
+
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	if err != nil {
+		cleanup()
+		log.Fatalf("Failed to read shoebox report, %v", err)
+	}
+
+	fmt.Println(rpt.String())
+
+	// Note that the picturebook application does not (yet) stop when a source bucket yields an error
+	// so the -on-error=fail policy is enforced here, by removing the (incomplete) picturebook it created.
+
+	if rpt.Error != "" {
+
+		cleanup()
+
+		err := removePicturebook(ctx, target_uri, filename)
+
+		if err != nil {
+			log.Fatalf("Failed to gather pictures, %s. Failed to remove incomplete picturebook %s, %v", rpt.Error, filename, err)
+		}
+
+		log.Fatalf("Failed to gather pictures, your picturebook was not created. %s", rpt.Error)
+	}
+
+	// Checkpoints are not removed by the bucket once all the pictures have been gathered since the picturebook
+	// application may still fail to create the picturebook.
+
+	if checkpoint_uri != "" {
+
+		err := bucket.RemoveCheckpoint(ctx, source_uri)
+
+		if err != nil {
+			slog.Warn("Failed to remove checkpoint", "error", err)
+		}
+	}
+}
+
+// removePicturebook removes the picturebook file 'filename' from the aaronland/go-picturebook/bucket.Bucket defined by 'target_uri'.
+// As with the picturebook application 'target_uri' may be the path to a local directory.
+func removePicturebook(ctx context.Context, target_uri string, filename string) error {
+
+	if !strings.Contains(target_uri, "://") {
+
+		abs_path, err := filepath.Abs(target_uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive absolute path for %s, %w", target_uri, err)
+		}
+
+		target_u := url.URL{
+			Scheme: "file",
+			Path:   abs_path,
+		}
+
+		target_uri = target_u.String()
+	}
+
+	target_bucket, err := pb_bucket.NewBucket(ctx, target_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to open target bucket, %w", err)
+	}
+
+	defer target_bucket.Close()
+
+	return target_bucket.Delete(ctx, filename)
+}
+
+// captionLines is the maximum number of lines in the captions produced by the shoebox:// caption: two or three lines for
+// the object (see `response.ImageCaption`), an empty line, its URL, where it is on display and when it was collected.
+const captionLines int = 7
+
+// printableArea returns the width and height, in inches, of the area available to each image derived from the same flags
+// (-size, -width, -height, -units, -orientation, -dpi, -border, -bleed and -margin-(N)) used to create the picturebook. The
+// area is derived by aaronland/go-picturebook itself less the height it reserves for the longest caption (see `captionLines`).
+func printableArea(ctx context.Context) (float64, float64, error) {
+
+	pb_opts, err := pb.NewPictureBookDefaultOptions(ctx)
+
+	if err != nil {
+		return 0.0, 0.0, fmt.Errorf("Failed to create picturebook options, %w", err)
+	}
+
+	pb_opts.Orientation = orientation
+	pb_opts.Size = size
+	pb_opts.Width = width
+	pb_opts.Height = height
+	pb_opts.Units = units
+	pb_opts.DPI = dpi
+	pb_opts.Border = border
+	pb_opts.Bleed = bleed
+	pb_opts.MarginTop = margin_top
+	pb_opts.MarginBottom = margin_bottom
+	pb_opts.MarginLeft = margin_left
+	pb_opts.MarginRight = margin_right
+
+	book, err := pb.NewPictureBook(ctx, pb_opts)
+
+	if err != nil {
+		return 0.0, 0.0, fmt.Errorf("Failed to create picturebook, %w", err)
+	}
+
+	// This is the same calculation used by aaronland/go-picturebook when adding an image with a caption
+
+	font_sz, _ := book.PDF.GetFontSize()
+	caption_h := (font_sz + 2 + book.Text.Margin) * float64(captionLines)
+
+	w := book.Canvas.Width
+	h := book.Canvas.Height - caption_h
+
+	if w <= 0.0 || h <= 0.0 {
+		return 0.0, 0.0, fmt.Errorf("Margins exceed page size")
+	}
+
+	return w / dpi, h / dpi, nil
 }
@@ -0,0 +1,220 @@
+// package dates provides methods for parsing the date ranges used to select items in a SFO Museum shoebox.
+package dates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// DEFAULT_TIMEZONE is the default timezone used to derive the boundaries of date ranges.
+const DEFAULT_TIMEZONE string = "America/Los_Angeles"
+
+var re_year = regexp.MustCompile(`^(\d{4})$`)
+
+var re_month = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+
+var re_day = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+
+var re_quarter = regexp.MustCompile(`^(\d{4})-?[Qq]([1-4])$`)
+
+var re_relative = regexp.MustCompile(`^last:(\d+)([dwmy])$`)
+
+// Range is a range of time starting (inclusive) at `Start` and ending (exclusive) at `End`. Either value
+// may be the zero time in which case the range is open-ended.
+type Range struct {
+	// The start (inclusive) of the range.
+	Start time.Time
+	// The end (exclusive) of the range.
+	End time.Time
+}
+
+// MinDate returns the Unix timestamp for the start of 'r' or zero if 'r' does not have a start date.
+func (r *Range) MinDate() int64 {
+
+	if r.Start.IsZero() {
+		return 0
+	}
+
+	return r.Start.Unix()
+}
+
+// MaxDate returns the Unix timestamp for the last second of 'r' or zero if 'r' does not have an end date.
+func (r *Range) MaxDate() int64 {
+
+	if r.End.IsZero() {
+		return 0
+	}
+
+	return r.End.Unix() - 1
+}
+
+// LoadLocation returns the `time.Location` instance for 'tz' or `DEFAULT_TIMEZONE` if 'tz' is empty.
+func LoadLocation(tz string) (*time.Location, error) {
+
+	if tz == "" {
+		tz = DEFAULT_TIMEZONE
+	}
+
+	loc, err := time.LoadLocation(tz)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load timezone '%s', %w", tz, err)
+	}
+
+	return loc, nil
+}
+
+// ParseRange returns a new `Range` instance derived from 'str' whose boundaries are calculated in the 'loc' timezone.
+// Relative ranges are calculated from 'now'. Valid values for 'str' are:
+//   - A year, for example "2024".
+//   - A month, for example "2024-03".
+//   - A day, for example "2024-03-15".
+//   - A quarter, for example "2024-Q1" or "2024Q1".
+//   - A RFC 3339 timestamp, for example "2024-03-15T12:00:00-07:00".
+//   - A relative window ending at 'now', for example "last:90d". Valid units are "d" (days), "w" (weeks), "m" (months) and "y" (years).
+//   - Two of the above (excluding relative windows) separated by a "/", for example "2024-01-01/2024-06-30". Either side may be empty
+//     in which case the range is open-ended.
+func ParseRange(str string, loc *time.Location, now time.Time) (*Range, error) {
+
+	str = strings.TrimSpace(str)
+
+	if str == "" {
+		return nil, fmt.Errorf("Empty date")
+	}
+
+	if strings.HasPrefix(str, "last:") {
+		return parseRelative(str, loc, now)
+	}
+
+	if strings.Contains(str, "/") {
+
+		parts := strings.Split(str, "/")
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid date range '%s'", str)
+		}
+
+		r := &Range{}
+
+		if parts[0] != "" {
+
+			start, err := parseDate(parts[0], loc)
+
+			if err != nil {
+				return nil, err
+			}
+
+			r.Start = start.Start
+		}
+
+		if parts[1] != "" {
+
+			end, err := parseDate(parts[1], loc)
+
+			if err != nil {
+				return nil, err
+			}
+
+			r.End = end.End
+		}
+
+		if r.Start.IsZero() && r.End.IsZero() {
+			return nil, fmt.Errorf("Invalid date range '%s'", str)
+		}
+
+		if !r.Start.IsZero() && !r.End.IsZero() && !r.Start.Before(r.End) {
+			return nil, fmt.Errorf("Invalid date range '%s', start date must be before end date", str)
+		}
+
+		return r, nil
+	}
+
+	return parseDate(str, loc)
+}
+
+// parseDate returns a new `Range` instance spanning the year, quarter, month, day or second described by 'str'.
+func parseDate(str string, loc *time.Location) (*Range, error) {
+
+	atoi := func(s string) int {
+		i, _ := strconv.Atoi(s)
+		return i
+	}
+
+	if m := re_year.FindStringSubmatch(str); m != nil {
+		start := time.Date(atoi(m[1]), time.January, 1, 0, 0, 0, 0, loc)
+		return &Range{Start: start, End: start.AddDate(1, 0, 0)}, nil
+	}
+
+	if m := re_quarter.FindStringSubmatch(str); m != nil {
+		month := time.Month((atoi(m[2])-1)*3 + 1)
+		start := time.Date(atoi(m[1]), month, 1, 0, 0, 0, 0, loc)
+		return &Range{Start: start, End: start.AddDate(0, 3, 0)}, nil
+	}
+
+	if m := re_month.FindStringSubmatch(str); m != nil {
+
+		month := atoi(m[2])
+
+		if month < 1 || month > 12 {
+			return nil, fmt.Errorf("Invalid month '%s'", str)
+		}
+
+		start := time.Date(atoi(m[1]), time.Month(month), 1, 0, 0, 0, 0, loc)
+		return &Range{Start: start, End: start.AddDate(0, 1, 0)}, nil
+	}
+
+	if re_day.MatchString(str) {
+
+		t, err := time.ParseInLocation(time.DateOnly, str, loc)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid date '%s', %w", str, err)
+		}
+
+		return &Range{Start: t, End: t.AddDate(0, 0, 1)}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, str)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid date '%s'", str)
+	}
+
+	return &Range{Start: t, End: t.Add(time.Second)}, nil
+}
+
+// parseRelative returns a new `Range` instance for the relative window described by 'str' ending at 'now'.
+func parseRelative(str string, loc *time.Location, now time.Time) (*Range, error) {
+
+	m := re_relative.FindStringSubmatch(str)
+
+	if m == nil {
+		return nil, fmt.Errorf("Invalid relative date '%s'", str)
+	}
+
+	n, err := strconv.Atoi(m[1])
+
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("Invalid relative date '%s'", str)
+	}
+
+	end := now.In(loc)
+	var start time.Time
+
+	switch m[2] {
+	case "d":
+		start = end.AddDate(0, 0, -n)
+	case "w":
+		start = end.AddDate(0, 0, -7*n)
+	case "m":
+		start = end.AddDate(0, -n, 0)
+	case "y":
+		start = end.AddDate(-n, 0, 0)
+	}
+
+	return &Range{Start: start, End: end}, nil
+}
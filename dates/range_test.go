@@ -0,0 +1,101 @@
+package dates
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+
+	loc, err := LoadLocation("")
+
+	if err != nil {
+		t.Fatalf("Failed to load default location, %v", err)
+	}
+
+	now := time.Date(2024, time.July, 4, 12, 0, 0, 0, loc)
+
+	// Expected values are RFC 3339 strings for the start and end of each range
+
+	tests := map[string][2]string{
+		"2024":                  {"2024-01-01T00:00:00-08:00", "2025-01-01T00:00:00-08:00"},
+		"2024-03":               {"2024-03-01T00:00:00-08:00", "2024-04-01T00:00:00-07:00"},
+		"2024-03-15":            {"2024-03-15T00:00:00-07:00", "2024-03-16T00:00:00-07:00"},
+		"2024-Q2":               {"2024-04-01T00:00:00-07:00", "2024-07-01T00:00:00-07:00"},
+		"2024q4":                {"2024-10-01T00:00:00-07:00", "2025-01-01T00:00:00-08:00"},
+		"2024-01-01/2024-06-30": {"2024-01-01T00:00:00-08:00", "2024-07-01T00:00:00-07:00"},
+		"2023/2024-Q1":          {"2023-01-01T00:00:00-08:00", "2024-04-01T00:00:00-07:00"},
+		"last:90d":              {"2024-04-05T12:00:00-07:00", "2024-07-04T12:00:00-07:00"},
+		"last:1y":               {"2023-07-04T12:00:00-07:00", "2024-07-04T12:00:00-07:00"},
+	}
+
+	for str, expected := range tests {
+
+		r, err := ParseRange(str, loc, now)
+
+		if err != nil {
+			t.Fatalf("Failed to parse '%s', %v", str, err)
+		}
+
+		if r.Start.Format(time.RFC3339) != expected[0] {
+			t.Fatalf("Unexpected start for '%s': %s", str, r.Start.Format(time.RFC3339))
+		}
+
+		if r.End.Format(time.RFC3339) != expected[1] {
+			t.Fatalf("Unexpected end for '%s': %s", str, r.End.Format(time.RFC3339))
+		}
+	}
+
+	// Open-ended ranges
+
+	r, err := ParseRange("2024-06/", loc, now)
+
+	if err != nil {
+		t.Fatalf("Failed to parse open-ended range, %v", err)
+	}
+
+	if r.MinDate() != 1717225200 || r.MaxDate() != 0 {
+		t.Fatalf("Unexpected open-ended range: %d, %d", r.MinDate(), r.MaxDate())
+	}
+
+	invalid := []string{
+		"",
+		"24",
+		"2024-13",
+		"2024-02-30",
+		"2024-Q5",
+		"last:90",
+		"last:0d",
+		"/",
+		"2024/2023",
+		"2024/2025/2026",
+	}
+
+	for _, str := range invalid {
+
+		_, err := ParseRange(str, loc, now)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str)
+		}
+	}
+}
+
+func TestPacificBoundaries(t *testing.T) {
+
+	loc, _ := LoadLocation("")
+
+	r, err := ParseRange("2024", loc, time.Now())
+
+	if err != nil {
+		t.Fatalf("Failed to parse range, %v", err)
+	}
+
+	// 2024-12-31 23:30 Pacific is 2025-01-01 07:30 UTC
+
+	late := time.Date(2025, time.January, 1, 7, 30, 0, 0, time.UTC).Unix()
+
+	if late < r.MinDate() || late > r.MaxDate() {
+		t.Fatalf("Expected %d to fall within %d and %d", late, r.MinDate(), r.MaxDate())
+	}
+}
@@ -4,15 +4,20 @@ go 1.25.0
 
 require (
 	github.com/aaronland/go-picturebook v0.15.5
+	github.com/aaronland/go-roster v1.0.0
 	github.com/dgraph-io/ristretto/v2 v2.4.0
+	github.com/fogleman/gg v1.3.0
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/jtacoma/uritemplates v1.0.0
 	github.com/mitchellh/go-wordwrap v1.0.1
 	github.com/rainycape/unidecode v0.0.0-20150907023854-cb7f23ec59be
 	github.com/sfomuseum/go-flags v0.12.1
+	github.com/sfomuseum/go-font-ocra v0.0.3
 	github.com/sfomuseum/go-sfomuseum-api/v2 v2.0.2
 	github.com/tidwall/gjson v1.18.0
 	github.com/whosonfirst/go-ioutil v1.0.2
 	gocloud.dev v0.45.0
+	golang.org/x/image v0.38.0
 )
 
 require (
@@ -21,7 +26,6 @@ require (
 	github.com/aaronland/go-image-contour/v2 v2.1.0 // indirect
 	github.com/aaronland/go-image-halftone/v2 v2.0.0 // indirect
 	github.com/aaronland/go-image/v2 v2.1.4 // indirect
-	github.com/aaronland/go-roster v1.0.0 // indirect
 	github.com/aaronland/gocloud v1.0.4 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dsoprea/go-exif/v3 v3.0.1 // indirect
@@ -36,13 +40,11 @@ require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/fogleman/colormap v0.0.0-20240324153029-3da9a245d155 // indirect
 	github.com/fogleman/contourmap v0.0.0-20190814184649-9f61d36c4199 // indirect
-	github.com/fogleman/gg v1.3.0 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.13 // indirect
 	github.com/go-errors/errors v1.4.2 // indirect
 	github.com/go-logr/logr v1.4.3 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/go-xmlfmt/xmlfmt v0.0.0-20191208150333-d5b6f63a941b // indirect
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/golang/geo v0.0.0-20210211234256-740aa86cb551 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/googleapis/gax-go/v2 v2.15.0 // indirect
@@ -54,7 +56,6 @@ require (
 	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd // indirect
 	github.com/schollz/progressbar/v3 v3.19.0 // indirect
 	github.com/sfomuseum/go-exif-update v0.2.1 // indirect
-	github.com/sfomuseum/go-font-ocra v0.0.3 // indirect
 	github.com/strukturag/libheif-go v0.0.0-20250130134905-55b3482bea15 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
@@ -65,7 +66,6 @@ require (
 	go.opentelemetry.io/otel/sdk/metric v1.43.0 // indirect
 	go.opentelemetry.io/otel/trace v1.43.0 // indirect
 	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
-	golang.org/x/image v0.38.0 // indirect
 	golang.org/x/net v0.48.0 // indirect
 	golang.org/x/sys v0.42.0 // indirect
 	golang.org/x/term v0.38.0 // indirect
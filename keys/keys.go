@@ -0,0 +1,226 @@
+// package keys provides methods for encoding and parsing the keys (URIs) of the pictures gathered from a SFO Museum shoebox.
+//
+// Keys are the URI of a picture with details about the shoebox item it was derived from encoded in the URL fragment. For example:
+//
+//	https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1011;size=k;type=object
+//
+// The URL fragment starts with a version number followed by zero or more semi-colon separated "{NAME}={VALUE}" pairs. Fields which
+// are not recognized are ignored so that new fields can be added without breaking older code. Keys created before the fragment was
+// versioned, which take the form of "{URI}#{LABEL}:{ID}:{ITEM_ID}:{ITEM_CREATED}", can still be parsed.
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CURRENT_VERSION is the version of the URL fragment produced by the `Encode` method.
+const CURRENT_VERSION int = 1
+
+// ErrMissingFragment is returned when a key does not have a URL fragment.
+var ErrMissingFragment = errors.New("Key does not have a URL fragment")
+
+// ErrInvalidFragment is returned when the URL fragment of a key can not be parsed.
+var ErrInvalidFragment = errors.New("Invalid URL fragment")
+
+// ErrUnsupportedVersion is returned when the URL fragment of a key has a version which is not supported.
+var ErrUnsupportedVersion = errors.New("Unsupported URL fragment version")
+
+var re_version = regexp.MustCompile(`^v(\d+)$`)
+
+// ShoeboxKey defines the details about a shoebox item encoded in the key for a picture derived from that item.
+type ShoeboxKey struct {
+	// The version of the URL fragment the key was parsed from. Zero for (legacy) keys without a version.
+	Version int
+	// The URI of the picture (without the URL fragment).
+	URI string
+	// The label of the `shoebox.Resolver` which derived the picture from the shoebox item.
+	Label string
+	// The identifier assigned by the resolver. This is the unique identifier of the shoebox item for most resolvers
+	// but may be the identifier of the item (for example an Instagram post) added to the shoebox.
+	Id int64
+	// The unique identifier of the item added to the shoebox.
+	ItemId int64
+	// The Unix timestamp when the item was added to the shoebox. Zero if the item was not added to a shoebox.
+	Created int64
+	// The unique identifier of the image, if known.
+	ImageId int64
+	// The size label of the image, if known.
+	Size string
+	// The name of the type of item added to the shoebox, if known.
+	Type string
+	// The unique identifier of the exhibition the item is on display in, if known.
+	Exhibition int64
+	// The name of the gallery the item is on display in, if known.
+	Gallery string
+	// The name of the case the item is on display in, if known.
+	Case string
+}
+
+// Encode returns the key for the picture defined by 'k' using the current URL fragment version.
+func Encode(k *ShoeboxKey) string {
+
+	fields := []string{
+		fmt.Sprintf("v%d", CURRENT_VERSION),
+		field("label", k.Label),
+		field("id", strconv.FormatInt(k.Id, 10)),
+		field("item", strconv.FormatInt(k.ItemId, 10)),
+	}
+
+	// Items which weren't collected in a shoebox (for example objects gathered by the collection:// bucket) don't have a created date
+
+	if k.Created != 0 {
+		fields = append(fields, field("created", strconv.FormatInt(k.Created, 10)))
+	}
+
+	if k.ImageId != 0 {
+		fields = append(fields, field("image", strconv.FormatInt(k.ImageId, 10)))
+	}
+
+	if k.Size != "" {
+		fields = append(fields, field("size", k.Size))
+	}
+
+	if k.Type != "" {
+		fields = append(fields, field("type", k.Type))
+	}
+
+	if k.Exhibition != 0 {
+		fields = append(fields, field("exhibition", strconv.FormatInt(k.Exhibition, 10)))
+	}
+
+	if k.Gallery != "" {
+		fields = append(fields, field("gallery", k.Gallery))
+	}
+
+	if k.Case != "" {
+		fields = append(fields, field("case", k.Case))
+	}
+
+	return fmt.Sprintf("%s#%s", k.URI, strings.Join(fields, ";"))
+}
+
+// Parse returns a new `ShoeboxKey` instance derived from 'key'.
+func Parse(key string) (*ShoeboxKey, error) {
+
+	uri, fragment, found := strings.Cut(key, "#")
+
+	if !found || fragment == "" {
+		return nil, ErrMissingFragment
+	}
+
+	if !strings.Contains(fragment, ";") && !re_version.MatchString(fragment) {
+		return parseLegacy(uri, fragment)
+	}
+
+	fields := strings.Split(fragment, ";")
+
+	m := re_version.FindStringSubmatch(fields[0])
+
+	if m == nil {
+		return nil, fmt.Errorf("%w, missing version", ErrInvalidFragment)
+	}
+
+	version, err := strconv.Atoi(m[1])
+
+	if err != nil || version < 1 || version > CURRENT_VERSION {
+		return nil, fmt.Errorf("%w, %s", ErrUnsupportedVersion, fields[0])
+	}
+
+	k := &ShoeboxKey{
+		Version: version,
+		URI:     uri,
+	}
+
+	for _, f := range fields[1:] {
+
+		name, str_value, ok := strings.Cut(f, "=")
+
+		if !ok {
+			return nil, fmt.Errorf("%w, invalid field '%s'", ErrInvalidFragment, f)
+		}
+
+		value, err := url.QueryUnescape(str_value)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w, invalid value for '%s', %w", ErrInvalidFragment, name, err)
+		}
+
+		switch name {
+		case "label":
+			k.Label = value
+		case "id":
+			k.Id, err = strconv.ParseInt(value, 10, 64)
+		case "item":
+			k.ItemId, err = strconv.ParseInt(value, 10, 64)
+		case "created":
+			k.Created, err = strconv.ParseInt(value, 10, 64)
+		case "image":
+			k.ImageId, err = strconv.ParseInt(value, 10, 64)
+		case "size":
+			k.Size = value
+		case "type":
+			k.Type = value
+		case "exhibition":
+			k.Exhibition, err = strconv.ParseInt(value, 10, 64)
+		case "gallery":
+			k.Gallery = value
+		case "case":
+			k.Case = value
+		default:
+			// Ignore fields added by later versions
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w, invalid value for '%s', %w", ErrInvalidFragment, name, err)
+		}
+	}
+
+	if k.Label == "" {
+		return nil, fmt.Errorf("%w, missing label", ErrInvalidFragment)
+	}
+
+	return k, nil
+}
+
+// parseLegacy returns a new `ShoeboxKey` instance derived from a URL fragment which takes the form of "{LABEL}:{ID}:{ITEM_ID}:{ITEM_CREATED}".
+func parseLegacy(uri string, fragment string) (*ShoeboxKey, error) {
+
+	parts := strings.Split(fragment, ":")
+
+	if len(parts) != 4 || parts[0] == "" {
+		return nil, ErrInvalidFragment
+	}
+
+	ids := make([]int64, 3)
+
+	for idx, str_id := range parts[1:] {
+
+		id, err := strconv.ParseInt(str_id, 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w, %w", ErrInvalidFragment, err)
+		}
+
+		ids[idx] = id
+	}
+
+	k := &ShoeboxKey{
+		URI:     uri,
+		Label:   parts[0],
+		Id:      ids[0],
+		ItemId:  ids[1],
+		Created: ids[2],
+	}
+
+	return k, nil
+}
+
+// field returns a "{NAME}={VALUE}" pair for the URL fragment of a key.
+func field(name string, value string) string {
+	return fmt.Sprintf("%s=%s", name, url.QueryEscape(value))
+}
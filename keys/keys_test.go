@@ -0,0 +1,131 @@
+package keys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncodeParse(t *testing.T) {
+
+	k := &ShoeboxKey{
+		URI:     "https://static.sfomuseum.org/media/1011_k_k.jpg",
+		Label:   "o",
+		Id:      1,
+		ItemId:  101,
+		Created: 1704182400,
+		ImageId: 1011,
+		Size:    "k",
+		Type:    "object",
+	}
+
+	str_key := Encode(k)
+
+	if str_key != "https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1011;size=k;type=object" {
+		t.Fatalf("Unexpected key: %s", str_key)
+	}
+
+	k2, err := Parse(str_key)
+
+	if err != nil {
+		t.Fatalf("Failed to parse key, %v", err)
+	}
+
+	k.Version = CURRENT_VERSION
+
+	if *k2 != *k {
+		t.Fatalf("Unexpected parsed key: %v", k2)
+	}
+
+	// Optional fields are omitted and values are escaped
+
+	str_key = Encode(&ShoeboxKey{URI: "shoebox://card/5.png", Label: "card", Id: 5, ItemId: 401, Created: 1704528000, Type: "art gallery"})
+
+	if str_key != "shoebox://card/5.png#v1;label=card;id=5;item=401;created=1704528000;type=art+gallery" {
+		t.Fatalf("Unexpected key: %s", str_key)
+	}
+
+	k2, err = Parse(str_key)
+
+	if err != nil || k2.Type != "art gallery" || k2.ImageId != 0 {
+		t.Fatalf("Unexpected parsed key: %v, %v", k2, err)
+	}
+
+	// Items which weren't collected in a shoebox don't have a created date
+
+	str_key = Encode(&ShoeboxKey{URI: "https://static.sfomuseum.org/media/1011_k_k.jpg", Label: "o", Id: 101, ItemId: 101, ImageId: 1011})
+
+	if str_key != "https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=101;item=101;image=1011" {
+		t.Fatalf("Unexpected key: %s", str_key)
+	}
+
+	// Exhibition context
+
+	k.Exhibition = 1729
+	k.Gallery = "Terminal 2; Departures"
+	k.Case = "Case 4"
+
+	str_key = Encode(k)
+
+	if str_key != "https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1011;size=k;type=object;exhibition=1729;gallery=Terminal+2%3B+Departures;case=Case+4" {
+		t.Fatalf("Unexpected key: %s", str_key)
+	}
+
+	k2, err = Parse(str_key)
+
+	if err != nil || *k2 != *k {
+		t.Fatalf("Unexpected parsed key: %v, %v", k2, err)
+	}
+}
+
+func TestParse(t *testing.T) {
+
+	// Legacy keys
+
+	k, err := Parse("https://static.sfomuseum.org/media/2010_ig_b.jpg#ig:2010:201:1704268800")
+
+	if err != nil {
+		t.Fatalf("Failed to parse legacy key, %v", err)
+	}
+
+	if k.Version != 0 || k.Label != "ig" || k.Id != 2010 || k.ItemId != 201 || k.Created != 1704268800 || k.URI != "https://static.sfomuseum.org/media/2010_ig_b.jpg" {
+		t.Fatalf("Unexpected legacy key: %v", k)
+	}
+
+	// Unknown fields are ignored
+
+	k, err = Parse("https://example.com/1.jpg#v1;label=o;id=1;item=101;created=1704182400;colour=blue")
+
+	if err != nil || k.ItemId != 101 {
+		t.Fatalf("Expected unknown fields to be ignored: %v, %v", k, err)
+	}
+
+	_, err = Parse("https://example.com/1.jpg")
+
+	if !errors.Is(err, ErrMissingFragment) {
+		t.Fatalf("Expected missing fragment error, %v", err)
+	}
+
+	_, err = Parse("https://example.com/1.jpg#v99;label=o")
+
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("Expected unsupported version error, %v", err)
+	}
+
+	invalid := []string{
+		"https://example.com/1.jpg#o:1:101",
+		"https://example.com/1.jpg#o:1:101:yesterday",
+		"https://example.com/1.jpg#v1;label=o;id=one",
+		"https://example.com/1.jpg#v1;id=1",
+		"https://example.com/1.jpg#v1;label",
+		"https://example.com/1.jpg#label=o;id=1",
+	}
+
+	for _, str_key := range invalid {
+
+		_, err := Parse(str_key)
+
+		if !errors.Is(err, ErrInvalidFragment) {
+			t.Fatalf("Expected '%s' to be invalid, %v", str_key, err)
+		}
+	}
+}
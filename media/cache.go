@@ -0,0 +1,282 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/whosonfirst/go-ioutil"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+)
+
+// Cache provides a persistent, content-addressed cache for media files stored in a `gocloud.dev/blob.Bucket` instance.
+// Cached files are stored using the SHA-256 hash of their contents as a key. A separate index record, keyed by the
+// SHA-256 hash of a media file's URL, records the hash of its contents alongside its ETag, Last-Modified and
+// Content-Length headers.
+type Cache struct {
+	bucket *blob.Bucket
+	// revalidate is a boolean flag signaling that cached media files should be revalidated using a conditional request.
+	revalidate bool
+}
+
+// CacheEntry defines the index record for a media file stored in a `Cache`.
+type CacheEntry struct {
+	// URI is the URL of the media file.
+	URI string `json:"uri"`
+	// ETag is the value of the ETag header returned when the media file was retrieved.
+	ETag string `json:"etag,omitempty"`
+	// LastModified is the value of the Last-Modified header returned when the media file was retrieved.
+	LastModified string `json:"last_modified,omitempty"`
+	// ContentLength is the size, in bytes, of the media file.
+	ContentLength int64 `json:"content_length"`
+	// SHA256 is the SHA-256 hash of the contents of the media file.
+	SHA256 string `json:"sha256"`
+	// Created is the Unix timestamp when the media file was added to the cache.
+	Created int64 `json:"created"`
+}
+
+// NewCache returns a new `Cache` instance whose media files are stored in the `gocloud.dev/blob.Bucket` defined by 'bucket_uri'.
+// If 'revalidate' is true then cached media files will be revalidated using conditional (If-None-Match, If-Modified-Since) requests.
+// Otherwise cached media files are used without performing any network requests at all.
+func NewCache(ctx context.Context, bucket_uri string, revalidate bool) (*Cache, error) {
+
+	b, err := blob.OpenBucket(ctx, bucket_uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open cache bucket, %w", err)
+	}
+
+	c := &Cache{
+		bucket:     b,
+		revalidate: revalidate,
+	}
+
+	return c, nil
+}
+
+// Fetch returns an `io.ReadSeekCloser` instance for the media file at 'uri' reading it from the cache if present and
+// otherwise retrieving it with 'http_client' and then adding it to the cache. The URL fragment of 'uri', if present, is
+// ignored so the same media file is only cached once regardless of the fragment.
+func (c *Cache) Fetch(ctx context.Context, http_client *http.Client, uri string) (io.ReadSeekCloser, error) {
+
+	uri = stripFragment(uri)
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	entry, body, err := c.read(ctx, uri)
+
+	if err != nil {
+		logger.Warn("Failed to read media file from cache, refetching", "error", err)
+		entry = nil
+	}
+
+	if entry != nil && !c.revalidate {
+		return ioutil.NewReadSeekCloser(bytes.NewReader(body))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request for %s, %w", uri, err)
+	}
+
+	if entry != nil {
+
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	rsp, err := http_client.Do(req)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve %s, %w", uri, err)
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode == http.StatusNotModified && entry != nil {
+		logger.Debug("Media file not modified, using cached copy")
+		return ioutil.NewReadSeekCloser(bytes.NewReader(body))
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to retrieve %s, %d %s", uri, rsp.StatusCode, rsp.Status)
+	}
+
+	body, err = io.ReadAll(rsp.Body)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read body for %s, %w", uri, err)
+	}
+
+	if rsp.ContentLength >= 0 && int64(len(body)) != rsp.ContentLength {
+		return nil, fmt.Errorf("Failed to retrieve %s, expected %d bytes but received %d", uri, rsp.ContentLength, len(body))
+	}
+
+	entry = &CacheEntry{
+		URI:           uri,
+		ETag:          rsp.Header.Get("ETag"),
+		LastModified:  rsp.Header.Get("Last-Modified"),
+		ContentLength: int64(len(body)),
+		SHA256:        hashBytes(body),
+		Created:       time.Now().Unix(),
+	}
+
+	err = c.write(ctx, entry, body)
+
+	if err != nil {
+		logger.Warn("Failed to add media file to cache", "error", err)
+	}
+
+	return ioutil.NewReadSeekCloser(bytes.NewReader(body))
+}
+
+// Entry returns the `CacheEntry` for 'uri' or nil if it is not present in the cache. The URL fragment of 'uri', if present, is ignored.
+// Index records without a valid SHA-256 hash are removed and treated as though they were not present in the cache.
+func (c *Cache) Entry(ctx context.Context, uri string) (*CacheEntry, error) {
+
+	index_key := indexKey(stripFragment(uri))
+
+	index_body, err := c.bucket.ReadAll(ctx, index_key)
+
+	if err != nil {
+
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("Failed to read cache index, %w", err)
+	}
+
+	var entry *CacheEntry
+
+	err = json.Unmarshal(index_body, &entry)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal cache index, %w", err)
+	}
+
+	if entry == nil || !isHash(entry.SHA256) {
+		slog.Warn("Cache index record has an invalid SHA-256 hash, removing", "uri", uri)
+		c.bucket.Delete(ctx, index_key)
+		return nil, nil
+	}
+
+	return entry, nil
+}
+
+// Close closes the underlying `gocloud.dev/blob.Bucket` instance for 'c'.
+func (c *Cache) Close() error {
+	return c.bucket.Close()
+}
+
+// read returns the `CacheEntry` and contents of the media file for 'uri', or nil if it is not present in the cache. If
+// the cached contents do not match the length or SHA-256 hash recorded in the index both the contents and the index record are removed.
+func (c *Cache) read(ctx context.Context, uri string) (*CacheEntry, []byte, error) {
+
+	entry, err := c.Entry(ctx, uri)
+
+	if err != nil || entry == nil {
+		return nil, nil, err
+	}
+
+	body, err := c.bucket.ReadAll(ctx, contentKey(entry.SHA256))
+
+	if err != nil {
+
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			c.bucket.Delete(ctx, indexKey(uri))
+			return nil, nil, nil
+		}
+
+		return nil, nil, fmt.Errorf("Failed to read cached media file, %w", err)
+	}
+
+	if int64(len(body)) != entry.ContentLength || hashBytes(body) != entry.SHA256 {
+		c.bucket.Delete(ctx, contentKey(entry.SHA256))
+		c.bucket.Delete(ctx, indexKey(uri))
+		return nil, nil, fmt.Errorf("Cached media file failed integrity check")
+	}
+
+	return entry, body, nil
+}
+
+// write adds 'body' and its index record 'entry' to the cache.
+func (c *Cache) write(ctx context.Context, entry *CacheEntry, body []byte) error {
+
+	content_key := contentKey(entry.SHA256)
+
+	exists, err := c.bucket.Exists(ctx, content_key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to determine whether cached media file exists, %w", err)
+	}
+
+	if !exists {
+
+		err = c.bucket.WriteAll(ctx, content_key, body, nil)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write cached media file, %w", err)
+		}
+	}
+
+	index_body, err := json.Marshal(entry)
+
+	if err != nil {
+		return fmt.Errorf("Failed to marshal cache index, %w", err)
+	}
+
+	err = c.bucket.WriteAll(ctx, indexKey(entry.URI), index_body, nil)
+
+	if err != nil {
+		return fmt.Errorf("Failed to write cache index, %w", err)
+	}
+
+	return nil
+}
+
+// stripFragment returns 'uri' without its URL fragment.
+func stripFragment(uri string) string {
+	uri, _, _ = strings.Cut(uri, "#")
+	return uri
+}
+
+func indexKey(uri string) string {
+	return fmt.Sprintf("index/%s.json", hashBytes([]byte(uri)))
+}
+
+func contentKey(hash string) string {
+	return fmt.Sprintf("content/%s/%s", hash[0:2], hash)
+}
+
+// isHash returns a boolean value indicating whether 'hash' is a hex-encoded SHA-256 hash.
+func isHash(hash string) bool {
+
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+func hashBytes(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
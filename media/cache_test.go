@@ -0,0 +1,180 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	_ "gocloud.dev/blob/fileblob"
+)
+
+func TestCache(t *testing.T) {
+
+	ctx := context.Background()
+
+	body := []byte("not really a JPEG")
+
+	var requests int32
+	var not_modified int32
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		atomic.AddInt32(&requests, 1)
+
+		if req.Header.Get("If-None-Match") == `"abc"` {
+			atomic.AddInt32(&not_modified, 1)
+			rsp.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		rsp.Header().Set("ETag", `"abc"`)
+		rsp.Write(body)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	uri := fmt.Sprintf("%s/media/1_abc_k.jpg", server.URL)
+
+	root := t.TempDir()
+	cache_uri := fmt.Sprintf("file://%s", root)
+
+	c, err := NewCache(ctx, cache_uri, false)
+
+	if err != nil {
+		t.Fatalf("Failed to create cache, %v", err)
+	}
+
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+
+		r, err := c.Fetch(ctx, http.DefaultClient, uri)
+
+		if err != nil {
+			t.Fatalf("Failed to fetch %s, %v", uri, err)
+		}
+
+		cached, err := io.ReadAll(r)
+		r.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to read %s, %v", uri, err)
+		}
+
+		if string(cached) != string(body) {
+			t.Fatalf("Unexpected body '%s'", string(cached))
+		}
+	}
+
+	// The URL fragment is ignored
+
+	r, err := c.Fetch(ctx, http.DefaultClient, uri+"#v1;label=o;id=1;item=101")
+
+	if err != nil {
+		t.Fatalf("Failed to fetch %s with fragment, %v", uri, err)
+	}
+
+	r.Close()
+
+	if requests != 1 {
+		t.Fatalf("Expected 1 request, got %d", requests)
+	}
+
+	entry, err := c.Entry(ctx, uri)
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve cache entry, %v", err)
+	}
+
+	if entry == nil || entry.ETag != `"abc"` || entry.ContentLength != int64(len(body)) {
+		t.Fatalf("Unexpected cache entry, %v", entry)
+	}
+
+	// Corrupt the cached file and ensure that it is refetched
+
+	content_path := filepath.Join(root, "content", entry.SHA256[0:2], entry.SHA256)
+
+	err = os.WriteFile(content_path, []byte("corrupted"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to corrupt cached file, %v", err)
+	}
+
+	r, err = c.Fetch(ctx, http.DefaultClient, uri)
+
+	if err != nil {
+		t.Fatalf("Failed to fetch %s after corruption, %v", uri, err)
+	}
+
+	r.Close()
+
+	if requests != 2 {
+		t.Fatalf("Expected 2 requests, got %d", requests)
+	}
+
+	// Index records with an invalid hash are treated as a cache miss and removed
+
+	index_path := filepath.Join(root, indexKey(uri))
+
+	for _, hash := range []string{"", "a", "not-a-sha256-hash"} {
+
+		err = os.WriteFile(index_path, []byte(fmt.Sprintf(`{"uri":"%s","sha256":"%s"}`, uri, hash)), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write invalid cache index, %v", err)
+		}
+
+		entry, err := c.Entry(ctx, uri)
+
+		if err != nil || entry != nil {
+			t.Fatalf("Expected invalid cache index for '%s' to be a miss, %v %v", hash, entry, err)
+		}
+
+		_, err = os.Stat(index_path)
+
+		if !os.IsNotExist(err) {
+			t.Fatalf("Expected invalid cache index for '%s' to be removed, %v", hash, err)
+		}
+	}
+
+	r, err = c.Fetch(ctx, http.DefaultClient, uri)
+
+	if err != nil {
+		t.Fatalf("Failed to fetch %s after invalid index, %v", uri, err)
+	}
+
+	r.Close()
+
+	if requests != 3 {
+		t.Fatalf("Expected 3 requests, got %d", requests)
+	}
+
+	// Revalidate
+
+	rc, err := NewCache(ctx, cache_uri, true)
+
+	if err != nil {
+		t.Fatalf("Failed to create revalidating cache, %v", err)
+	}
+
+	defer rc.Close()
+
+	r, err = rc.Fetch(ctx, http.DefaultClient, uri)
+
+	if err != nil {
+		t.Fatalf("Failed to fetch %s with revalidation, %v", uri, err)
+	}
+
+	r.Close()
+
+	if not_modified != 1 {
+		t.Fatalf("Expected conditional request")
+	}
+}
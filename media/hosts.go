@@ -0,0 +1,82 @@
+package media
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DEFAULT_MEDIA_URI is the default URI prefix for SFO Museum media files.
+const DEFAULT_MEDIA_URI string = "https://static.sfomuseum.org/media"
+
+// Hosts is an allow-list of URI prefixes for media files.
+type Hosts struct {
+	prefixes []string
+}
+
+// NewHosts returns a new `Hosts` instance derived from zero or more `?media_host=` query parameters in 'q'. Each
+// parameter may contain a comma-separated list of hosts (for example "static.example.com") or URI prefixes (for example
+// "http://localhost:8080/media"). Hosts without a scheme are assumed to be "https://" hosts. If there are no `?media_host=`
+// parameters then `DEFAULT_MEDIA_URI` is used.
+func NewHosts(q url.Values) (*Hosts, error) {
+
+	prefixes := make([]string, 0)
+
+	for _, v := range q["media_host"] {
+
+		for _, h := range strings.Split(v, ",") {
+
+			h = strings.TrimSpace(h)
+
+			if h == "" {
+				continue
+			}
+
+			if !strings.Contains(h, "://") {
+				h = fmt.Sprintf("https://%s/", h)
+			}
+
+			u, err := url.Parse(h)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse ?media_host= parameter '%s', %w", h, err)
+			}
+
+			if u.Host == "" {
+				return nil, fmt.Errorf("Invalid ?media_host= parameter '%s', missing host", h)
+			}
+
+			switch u.Scheme {
+			case "http", "https":
+				// pass
+			default:
+				return nil, fmt.Errorf("Invalid ?media_host= parameter '%s', unsupported scheme", h)
+			}
+
+			prefixes = append(prefixes, h)
+		}
+	}
+
+	if len(prefixes) == 0 {
+		prefixes = append(prefixes, DEFAULT_MEDIA_URI)
+	}
+
+	h := &Hosts{
+		prefixes: prefixes,
+	}
+
+	return h, nil
+}
+
+// IsValid returns a boolean value indicating whether 'uri' starts with one of the URI prefixes in 'h'.
+func (h *Hosts) IsValid(uri string) bool {
+
+	for _, prefix := range h.prefixes {
+
+		if strings.HasPrefix(uri, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package media
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// IMAGES_ALL signals that all the images for an object should be included.
+	IMAGES_ALL string = "all"
+	// IMAGES_PRIMARY signals that only the primary image for an object should be included.
+	IMAGES_PRIMARY string = "primary"
+	// IMAGES_FIRST signals that only the first N images for an object should be included.
+	IMAGES_FIRST string = "first"
+)
+
+// ImageSelection defines which of the images associated with an object should be included.
+type ImageSelection struct {
+	// Mode is one of `IMAGES_ALL`, `IMAGES_PRIMARY` or `IMAGES_FIRST`.
+	Mode string
+	// Count is the maximum number of images to include. Zero means there is no limit.
+	Count int
+}
+
+// NewImageSelection returns a new `ImageSelection` instance derived from the `?images=` query parameter in 'q'.
+// Valid options are:
+// - `all` Include all the images for an object. This is the default.
+// - `primary` Only include the primary image for an object (which is the first image returned by the `sfomuseum.collection.objects.getImages` API method).
+// - `first:{N}` Only include the first N images for an object.
+func NewImageSelection(q url.Values) (*ImageSelection, error) {
+
+	s := &ImageSelection{
+		Mode: IMAGES_ALL,
+	}
+
+	if !q.Has("images") {
+		return s, nil
+	}
+
+	str_mode := q.Get("images")
+	parts := strings.SplitN(str_mode, ":", 2)
+
+	switch parts[0] {
+	case IMAGES_ALL:
+
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("Invalid ?images= parameter '%s'", str_mode)
+		}
+
+	case IMAGES_PRIMARY:
+
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("Invalid ?images= parameter '%s'", str_mode)
+		}
+
+		s.Mode = IMAGES_PRIMARY
+		s.Count = 1
+
+	case IMAGES_FIRST:
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid ?images= parameter '%s', expected first:{N}", str_mode)
+		}
+
+		v, err := strconv.Atoi(parts[1])
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse count for ?images= parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid count for ?images= parameter, must be greater than zero")
+		}
+
+		s.Mode = IMAGES_FIRST
+		s.Count = v
+
+	default:
+		return nil, fmt.Errorf("Invalid or unsupported ?images= parameter '%s'", str_mode)
+	}
+
+	return s, nil
+}
+
+// Done returns a boolean value indicating whether 'seen' images (for a given object) satisfies the criteria defined by 's'.
+func (s *ImageSelection) Done(seen int) bool {
+	return s.Count > 0 && seen >= s.Count
+}
@@ -0,0 +1,145 @@
+package media
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// PHASH_SIZE is the width and height of the (grayscale) image that perceptual hashes are derived from.
+const PHASH_SIZE int = 32
+
+// PHASH_LOWFREQ_SIZE is the width and height of the block of low-frequency DCT coefficients used to derive perceptual hashes.
+const PHASH_LOWFREQ_SIZE int = 8
+
+// PHASH_THUMBNAIL_SIZE is the minimum width and height of the (smaller) version of an image used to derive its perceptual hash.
+const PHASH_THUMBNAIL_SIZE int = 256
+
+// PerceptualHash returns the 64-bit DCT-based perceptual hash for 'im'. The image is converted to grayscale and scaled
+// to 32 × 32 pixels. Each bit of the hash indicates whether the corresponding coefficient in the top-left 8 × 8 block of
+// its discrete cosine transform is greater than the median of those coefficients. This is the same method used by the
+// Python `imagehash.phash` function (with which the perceptual hashes for SFO Museum Instagram posts were derived) so
+// hashes should be comparable, though not necessarily identical.
+func PerceptualHash(im image.Image) uint64 {
+
+	gray := image.NewGray(image.Rect(0, 0, PHASH_SIZE, PHASH_SIZE))
+	draw.CatmullRom.Scale(gray, gray.Bounds(), im, im.Bounds(), draw.Src, nil)
+
+	pixels := make([][]float64, PHASH_SIZE)
+
+	for y := 0; y < PHASH_SIZE; y++ {
+
+		pixels[y] = make([]float64, PHASH_SIZE)
+
+		for x := 0; x < PHASH_SIZE; x++ {
+			pixels[y][x] = float64(gray.GrayAt(x, y).Y)
+		}
+	}
+
+	coeffs := dct2(pixels)
+
+	lowfreq := make([]float64, 0, PHASH_LOWFREQ_SIZE*PHASH_LOWFREQ_SIZE)
+
+	for y := 0; y < PHASH_LOWFREQ_SIZE; y++ {
+		lowfreq = append(lowfreq, coeffs[y][:PHASH_LOWFREQ_SIZE]...)
+	}
+
+	sorted := slices.Clone(lowfreq)
+	slices.Sort(sorted)
+
+	mid := len(sorted) / 2
+	median := (sorted[mid-1] + sorted[mid]) / 2
+
+	var hash uint64
+
+	for _, v := range lowfreq {
+
+		hash = hash << 1
+
+		if v > median {
+			hash = hash | 1
+		}
+	}
+
+	return hash
+}
+
+// ParsePerceptualHash returns the 64-bit perceptual hash encoded in the hexadecimal string 'str'.
+func ParsePerceptualHash(str string) (uint64, error) {
+
+	hash, err := strconv.ParseUint(str, 16, 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse perceptual hash '%s', %w", str, err)
+	}
+
+	return hash, nil
+}
+
+// FormatPerceptualHash returns 'hash' encoded as a (16 character) hexadecimal string.
+func FormatPerceptualHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// HammingDistance returns the number of bits which differ between the perceptual hashes 'a' and 'b'.
+func HammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// dct2 returns the (unnormalized) two-dimensional type-II discrete cosine transform of 'pixels', applied to each column and then each row.
+func dct2(pixels [][]float64) [][]float64 {
+
+	n := len(pixels)
+
+	cols := make([][]float64, n)
+
+	for y := 0; y < n; y++ {
+		cols[y] = make([]float64, n)
+	}
+
+	col := make([]float64, n)
+
+	for x := 0; x < n; x++ {
+
+		for y := 0; y < n; y++ {
+			col[y] = pixels[y][x]
+		}
+
+		for k, v := range dct1(col) {
+			cols[k][x] = v
+		}
+	}
+
+	coeffs := make([][]float64, n)
+
+	for y := 0; y < n; y++ {
+		coeffs[y] = dct1(cols[y])
+	}
+
+	return coeffs
+}
+
+// dct1 returns the (unnormalized) one-dimensional type-II discrete cosine transform of 'values'.
+func dct1(values []float64) []float64 {
+
+	n := len(values)
+	coeffs := make([]float64, n)
+
+	for k := 0; k < n; k++ {
+
+		sum := 0.0
+
+		for i, v := range values {
+			sum += v * math.Cos(math.Pi*float64(k)*(2.0*float64(i)+1.0)/(2.0*float64(n)))
+		}
+
+		coeffs[k] = 2.0 * sum
+	}
+
+	return coeffs
+}
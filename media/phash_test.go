@@ -0,0 +1,89 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+func TestPerceptualHash(t *testing.T) {
+
+	// A diagonal gradient with a dark square in one corner
+
+	gradient := image.NewRGBA(image.Rect(0, 0, 400, 300))
+
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+
+			v := uint8((x + y) * 255 / 700)
+
+			if x < 100 && y < 100 {
+				v = 0
+			}
+
+			gradient.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	// A checkerboard
+
+	checkers := image.NewRGBA(image.Rect(0, 0, 400, 300))
+
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+
+			v := uint8(0)
+
+			if (x/50+y/50)%2 == 0 {
+				v = 255
+			}
+
+			checkers.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	// A smaller copy of the gradient
+
+	smaller := image.NewRGBA(image.Rect(0, 0, 200, 150))
+	draw.BiLinear.Scale(smaller, smaller.Bounds(), gradient, gradient.Bounds(), draw.Src, nil)
+
+	a := PerceptualHash(gradient)
+	b := PerceptualHash(smaller)
+	c := PerceptualHash(checkers)
+
+	if HammingDistance(a, PerceptualHash(gradient)) != 0 {
+		t.Fatalf("Expected perceptual hashes for the same image to be equal")
+	}
+
+	if HammingDistance(a, b) > 4 {
+		t.Fatalf("Expected perceptual hashes for scaled images to be similar: %d", HammingDistance(a, b))
+	}
+
+	if HammingDistance(a, c) < 16 {
+		t.Fatalf("Expected perceptual hashes for different images to differ: %d", HammingDistance(a, c))
+	}
+
+	str_hash := FormatPerceptualHash(a)
+
+	if len(str_hash) != 16 {
+		t.Fatalf("Unexpected perceptual hash string: %s", str_hash)
+	}
+
+	v, err := ParsePerceptualHash(str_hash)
+
+	if err != nil {
+		t.Fatalf("Failed to parse perceptual hash, %v", err)
+	}
+
+	if v != a {
+		t.Fatalf("Unexpected parsed perceptual hash: %x", v)
+	}
+
+	_, err = ParsePerceptualHash("not a hash")
+
+	if err == nil {
+		t.Fatalf("Expected invalid perceptual hash to fail")
+	}
+}
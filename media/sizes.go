@@ -0,0 +1,247 @@
+// package media provides methods for selecting and resolving the URIs of SFO Museum object images.
+package media
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+// DEFAULT_SIZE_LABELS is the default ordered list of size labels used to select an object image.
+var DEFAULT_SIZE_LABELS = []string{
+	"o",
+	"k",
+	"b",
+	"c",
+}
+
+// ErrNoSuitableSize is returned when an image does not have any of the sizes defined by a `SizePreference`.
+var ErrNoSuitableSize = errors.New("Image does not have any of the following sizes")
+
+// SizePreference defines the criteria used to select a specific size of an object image.
+type SizePreference struct {
+	// Labels is the ordered list of size labels to consider when selecting an image size.
+	Labels []string
+	// MinWidth is the minimum width, in pixels, that an image needs to fill. If zero then width is not considered.
+	MinWidth int
+	// MinHeight is the minimum height, in pixels, that an image needs to fill. If zero then height is not considered.
+	MinHeight int
+}
+
+// NewSizePreference returns a new `SizePreference` instance derived from the following query parameters in 'q':
+// - `?sizes={LABEL},{LABEL}` An ordered, comma-separated list of size labels to consider. Default is "o,k,b,c".
+// - `?min_width={PIXELS}` The minimum width, in pixels, that an image needs to fill.
+// - `?min_height={PIXELS}` The minimum height, in pixels, that an image needs to fill.
+// - `?target_dpi={DPI}` Derive minimum dimensions from the `?page_width=` and `?page_height=` parameters (measured in inches) at this resolution.
+//
+// If a minimum width or height is defined then the smallest size (among the labels being considered) large enough to fill
+// those dimensions will be selected. Otherwise the first available size in the ordered list of labels will be selected.
+func NewSizePreference(q url.Values) (*SizePreference, error) {
+
+	p := &SizePreference{
+		Labels: DEFAULT_SIZE_LABELS,
+	}
+
+	if q.Has("sizes") {
+
+		labels := make([]string, 0)
+
+		for _, l := range strings.Split(q.Get("sizes"), ",") {
+
+			l = strings.TrimSpace(l)
+
+			if l != "" {
+				labels = append(labels, l)
+			}
+		}
+
+		if len(labels) == 0 {
+			return nil, fmt.Errorf("Invalid ?sizes= parameter, no labels defined")
+		}
+
+		p.Labels = labels
+	}
+
+	if q.Has("min_width") {
+
+		v, err := strconv.Atoi(q.Get("min_width"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?min_width= parameter, %w", err)
+		}
+
+		p.MinWidth = v
+	}
+
+	if q.Has("min_height") {
+
+		v, err := strconv.Atoi(q.Get("min_height"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?min_height= parameter, %w", err)
+		}
+
+		p.MinHeight = v
+	}
+
+	if q.Has("target_dpi") {
+
+		dpi, err := strconv.ParseFloat(q.Get("target_dpi"), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?target_dpi= parameter, %w", err)
+		}
+
+		if !q.Has("page_width") && !q.Has("page_height") {
+			return nil, fmt.Errorf("?target_dpi= parameter requires ?page_width= or ?page_height= parameters")
+		}
+
+		if q.Has("page_width") {
+
+			w, err := strconv.ParseFloat(q.Get("page_width"), 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse ?page_width= parameter, %w", err)
+			}
+
+			p.MinWidth = int(math.Ceil(w * dpi))
+		}
+
+		if q.Has("page_height") {
+
+			h, err := strconv.ParseFloat(q.Get("page_height"), 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse ?page_height= parameter, %w", err)
+			}
+
+			p.MinHeight = int(math.Ceil(h * dpi))
+		}
+	}
+
+	if p.MinWidth < 0 || p.MinHeight < 0 {
+		return nil, fmt.Errorf("Minimum dimensions must be greater than or equal to zero")
+	}
+
+	return p, nil
+}
+
+// Select returns the size label for 'im' that best matches the criteria defined in 'p'. If there are minimum dimensions
+// defined but none of the available sizes are large enough then the largest available size is returned.
+func (p *SizePreference) Select(im *response.ObjectImage) (string, error) {
+
+	var selected string
+	var selected_px int
+
+	var largest string
+	var largest_px int
+
+	for _, label := range p.Labels {
+
+		sz, exists := im.Size(label)
+
+		if !exists {
+			continue
+		}
+
+		if p.MinWidth == 0 && p.MinHeight == 0 {
+			return label, nil
+		}
+
+		px := sz.Width * sz.Height
+
+		if largest == "" || px > largest_px {
+			largest = label
+			largest_px = px
+		}
+
+		if !p.fills(sz) {
+			continue
+		}
+
+		if selected == "" || px < selected_px {
+			selected = label
+			selected_px = px
+		}
+	}
+
+	if selected != "" {
+		return selected, nil
+	}
+
+	if largest != "" {
+		return largest, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNoSuitableSize, strings.Join(p.Labels, ","))
+}
+
+// fills returns a boolean value indicating whether 'sz' is large enough to fill the minimum
+// dimensions defined by 'p' when scaled (preserving its aspect ratio) to fit those dimensions.
+func (p *SizePreference) fills(sz *response.ObjectImageSize) bool {
+
+	if sz.Width <= 0 || sz.Height <= 0 {
+		return false
+	}
+
+	scale := math.Inf(1)
+
+	if p.MinWidth > 0 {
+		scale = math.Min(scale, float64(p.MinWidth)/float64(sz.Width))
+	}
+
+	if p.MinHeight > 0 {
+		scale = math.Min(scale, float64(p.MinHeight)/float64(sz.Height))
+	}
+
+	return scale <= 1.0
+}
+
+// SelectThumbnail returns the label of the smallest size of 'im' whose width and height are both at least 'min' pixels and
+// which has the same aspect ratio as the size identified by 'label' (so that cropped sizes are never used) and a boolean
+// value indicating whether there is one. If there is no size smaller than 'label' which meets these criteria then 'label'
+// itself is returned.
+func SelectThumbnail(im *response.ObjectImage, label string, min int) (string, bool) {
+
+	sz, exists := im.Size(label)
+
+	if !exists || sz.Width <= 0 || sz.Height <= 0 {
+		return "", false
+	}
+
+	selected := label
+	selected_px := sz.Width * sz.Height
+
+	for _, l := range slices.Sorted(maps.Keys(im.Properties.Sizes)) {
+
+		t, exists := im.Size(l)
+
+		if !exists || t.Width < min || t.Height < min {
+			continue
+		}
+
+		// Allow for rounding when sizes are scaled
+
+		skew := math.Abs(float64(t.Width*sz.Height-sz.Width*t.Height)) / float64(sz.Width*t.Height)
+
+		if skew > 0.01 {
+			continue
+		}
+
+		px := t.Width * t.Height
+
+		if px < selected_px {
+			selected = l
+			selected_px = px
+		}
+	}
+
+	return selected, true
+}
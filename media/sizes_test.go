@@ -0,0 +1,119 @@
+package media
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+func TestSizePreference(t *testing.T) {
+
+	im := &response.ObjectImage{
+		Id:          1,
+		URITemplate: "https://static.sfomuseum.org/media/1_{secret}_{label}.{extension}",
+		Properties: &response.ObjectImageProperties{
+			Sizes: map[string]*response.ObjectImageSize{
+				"o": &response.ObjectImageSize{Secret: "o", Extension: "jpg", Width: 6000, Height: 4000},
+				"k": &response.ObjectImageSize{Secret: "k", Extension: "jpg", Width: 2048, Height: 1365},
+				"b": &response.ObjectImageSize{Secret: "b", Extension: "jpg", Width: 1024, Height: 683},
+				"c": &response.ObjectImageSize{Secret: "c", Extension: "jpg", Width: 800, Height: 533},
+			},
+		},
+	}
+
+	tests := map[string]string{
+		"":                              "o",
+		"sizes=b,c":                     "b",
+		"sizes=z,c":                     "c",
+		"min_width=1000":                "b",
+		"min_width=1500":                "k",
+		"min_width=10000":               "o",
+		"sizes=c,b&min_width=10000":     "b",
+		"target_dpi=150&page_width=6.5": "b",
+		"target_dpi=300&page_width=6.5": "k",
+		"target_dpi=150&page_width=6.5&page_height=3": "c",
+	}
+
+	for str_q, expected := range tests {
+
+		q, err := url.ParseQuery(str_q)
+
+		if err != nil {
+			t.Fatalf("Failed to parse query '%s', %v", str_q, err)
+		}
+
+		p, err := NewSizePreference(q)
+
+		if err != nil {
+			t.Fatalf("Failed to create size preference for '%s', %v", str_q, err)
+		}
+
+		label, err := p.Select(im)
+
+		if err != nil {
+			t.Fatalf("Failed to select size for '%s', %v", str_q, err)
+		}
+
+		if label != expected {
+			t.Fatalf("Unexpected label for '%s': %s (expected %s)", str_q, label, expected)
+		}
+	}
+}
+
+func TestSelectThumbnail(t *testing.T) {
+
+	im := &response.ObjectImage{
+		Id:          1,
+		URITemplate: "https://static.sfomuseum.org/media/1_{secret}_{label}.{extension}",
+		Properties: &response.ObjectImageProperties{
+			Sizes: map[string]*response.ObjectImageSize{
+				"k": &response.ObjectImageSize{Secret: "k", Extension: "jpg", Width: 2048, Height: 1365},
+				"c": &response.ObjectImageSize{Secret: "c", Extension: "jpg", Width: 800, Height: 533},
+				"n": &response.ObjectImageSize{Secret: "n", Extension: "jpg", Width: 320, Height: 213},
+				"q": &response.ObjectImageSize{Secret: "q", Extension: "jpg", Width: 300, Height: 300},
+			},
+		},
+	}
+
+	tests := map[int]string{
+		200:  "n",
+		256:  "c",
+		1000: "k",
+	}
+
+	for min, expected := range tests {
+
+		label, ok := SelectThumbnail(im, "k", min)
+
+		if !ok || label != expected {
+			t.Fatalf("Unexpected thumbnail for %d pixels: %s (expected %s)", min, label, expected)
+		}
+	}
+
+	_, ok := SelectThumbnail(im, "o", 256)
+
+	if ok {
+		t.Fatalf("Expected thumbnail for missing size to fail")
+	}
+}
+
+func TestSizePreferenceInvalid(t *testing.T) {
+
+	tests := []string{
+		"sizes=,",
+		"min_width=wide",
+		"target_dpi=300",
+	}
+
+	for _, str_q := range tests {
+
+		q, _ := url.ParseQuery(str_q)
+
+		_, err := NewSizePreference(q)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str_q)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package render
+
+import (
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// CardOptions defines configuration options for the `Card` method.
+type CardOptions struct {
+	// The width, in pixels, of the card. If zero then `DEFAULT_WIDTH` is used.
+	Width int
+	// The height, in pixels, of the card. If zero then `DEFAULT_HEIGHT` is used.
+	Height int
+	// The title to draw at the top of the card.
+	Title string
+	// Zero or more lines of text to draw below the title. Empty lines are drawn as blank space.
+	Lines []string
+}
+
+// Card returns a new `image.Image` instance containing a typographic "card" for the title and lines of text
+// defined in 'opts'. Cards are used in place of images for shoebox items which don't have an image of their own.
+func Card(opts *CardOptions) (image.Image, error) {
+
+	width := opts.Width
+	height := opts.Height
+
+	if width == 0 {
+		width = DEFAULT_WIDTH
+	}
+
+	if height == 0 {
+		height = DEFAULT_HEIGHT
+	}
+
+	w := float64(width)
+	h := float64(height)
+	margin := math.Min(w, h) * 0.08
+
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+
+	dc.SetRGB(0.85, 0.85, 0.85)
+	dc.SetLineWidth(math.Max(w/512.0, 1.0))
+	dc.DrawRectangle(margin/2, margin/2, w-margin, h-margin)
+	dc.Stroke()
+
+	text_w := w - (margin * 2)
+	y := margin
+
+	// Title
+
+	title_sz := h * 0.05
+
+	err := setFontFace(dc, title_sz)
+
+	if err != nil {
+		return nil, err
+	}
+
+	title_lines := dc.WordWrap(opts.Title, text_w)
+
+	dc.SetRGB(0, 0, 0)
+
+	for _, ln := range title_lines {
+		dc.DrawStringAnchored(ln, margin, y, 0.0, 1.0)
+		y += title_sz * 1.3
+	}
+
+	y += title_sz
+
+	// Lines
+
+	line_sz := h * 0.028
+
+	err = setFontFace(dc, line_sz)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dc.SetRGB(0.3, 0.3, 0.3)
+
+	for _, str := range opts.Lines {
+
+		if str == "" {
+			y += line_sz * 1.5
+			continue
+		}
+
+		for _, ln := range dc.WordWrap(str, text_w) {
+
+			if y > h-margin {
+				break
+			}
+
+			dc.DrawStringAnchored(ln, margin, y, 0.0, 1.0)
+			y += line_sz * 1.5
+		}
+	}
+
+	return dc.Image(), nil
+}
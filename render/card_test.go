@@ -0,0 +1,32 @@
+package render
+
+import (
+	"testing"
+)
+
+func TestCard(t *testing.T) {
+
+	opts := &CardOptions{
+		Width:  800,
+		Height: 600,
+		Title:  "Airline travel poster: United Air Lines, Hawaii",
+		Lines: []string{
+			"c. 1950",
+			"",
+			"2011.032.0001",
+			"https://collection.sfomuseum.org/objects/1511944253/",
+		},
+	}
+
+	im, err := Card(opts)
+
+	if err != nil {
+		t.Fatalf("Failed to render card, %v", err)
+	}
+
+	bounds := im.Bounds()
+
+	if bounds.Dx() != 800 || bounds.Dy() != 600 {
+		t.Fatalf("Unexpected dimensions: %v", bounds)
+	}
+}
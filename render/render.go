@@ -0,0 +1,87 @@
+// package render provides methods for generating page images for SFO Museum shoebox items that don't have a photograph of their own.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"sync"
+
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"github.com/sfomuseum/go-font-ocra/fonts"
+	"golang.org/x/image/font"
+)
+
+// DEFAULT_WIDTH is the default width, in pixels, of generated images.
+const DEFAULT_WIDTH int = 2048
+
+// DEFAULT_HEIGHT is the default height, in pixels, of generated images.
+const DEFAULT_HEIGHT int = 1536
+
+var ocra_font *truetype.Font
+
+var ocra_err error
+
+var ocra_once sync.Once
+
+// fontFace returns a new `font.Face` instance for the OCR-A font at 'points' size.
+func fontFace(points float64) (font.Face, error) {
+
+	ocra_once.Do(func() {
+
+		body, err := fonts.FS.ReadFile("OCRA.ttf")
+
+		if err != nil {
+			ocra_err = fmt.Errorf("Failed to read OCRA.ttf, %w", err)
+			return
+		}
+
+		f, err := truetype.Parse(body)
+
+		if err != nil {
+			ocra_err = fmt.Errorf("Failed to parse OCRA.ttf, %w", err)
+			return
+		}
+
+		ocra_font = f
+	})
+
+	if ocra_err != nil {
+		return nil, ocra_err
+	}
+
+	face := truetype.NewFace(ocra_font, &truetype.Options{
+		Size: points,
+	})
+
+	return face, nil
+}
+
+// setFontFace assigns a new OCR-A font face at 'points' size to 'dc'.
+func setFontFace(dc *gg.Context, points float64) error {
+
+	face, err := fontFace(points)
+
+	if err != nil {
+		return err
+	}
+
+	dc.SetFontFace(face)
+	return nil
+}
+
+// EncodePNG returns the PNG-encoded bytes for 'im'.
+func EncodePNG(im image.Image) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, im)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode PNG, %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,291 @@
+// package report provides methods for recording which SFO Museum shoebox items were skipped, or replaced by placeholders, while creating a picturebook.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/media"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+// REASON_MISSING_TEMPLATE is the reason recorded for items with images that don't have a URI template.
+const REASON_MISSING_TEMPLATE string = "missing_template"
+
+// REASON_NO_SIZES is the reason recorded for items with images that don't have any (suitable) sizes.
+const REASON_NO_SIZES string = "no_sizes"
+
+// REASON_HTTP_ERROR is the reason recorded for items which could not be resolved because of a network or HTTP error.
+const REASON_HTTP_ERROR string = "http_error"
+
+// REASON_UNSUPPORTED_TYPE is the reason recorded for items whose type is not supported.
+const REASON_UNSUPPORTED_TYPE string = "unsupported_type"
+
+// REASON_NO_IMAGES is the reason recorded for items which don't have any images.
+const REASON_NO_IMAGES string = "no_images"
+
+// REASON_OTHER is the reason recorded for items which could not be resolved for any other reason.
+const REASON_OTHER string = "other"
+
+// Entry is the list of shoebox items recorded for a specific reason.
+type Entry struct {
+	// The number of shoebox items recorded.
+	Count int `json:"count"`
+	// The (sorted) unique identifiers of the shoebox items (not the IDs of the things in the shoebox) recorded.
+	ItemIds []int64 `json:"item_ids"`
+}
+
+// Duplicate is a picture which was suppressed because it is a duplicate of a picture which was gathered earlier.
+type Duplicate struct {
+	// The unique identifier of the shoebox item the suppressed picture was derived from.
+	ItemId int64 `json:"item_id"`
+	// The URI of the suppressed picture.
+	URI string `json:"uri"`
+	// The unique identifier of the shoebox item the original picture was derived from.
+	OriginalItemId int64 `json:"original_item_id"`
+	// The URI of the original picture.
+	OriginalURI string `json:"original_uri"`
+	// The Hamming distance between the perceptual hashes of the suppressed and original pictures. Zero for identical URIs.
+	Distance int `json:"distance"`
+}
+
+// Report is a summary of the shoebox items which were skipped, or replaced by placeholders, while gathering pictures.
+// It is safe for concurrent use.
+type Report struct {
+	// The total number of shoebox items processed.
+	Items int `json:"items"`
+	// The total number of pictures gathered.
+	Pictures int `json:"pictures"`
+	// Shoebox items which were skipped (in whole or in part), keyed by reason.
+	Skipped map[string]*Entry `json:"skipped"`
+	// Shoebox items which were replaced by a placeholder image, keyed by reason.
+	Placeholders map[string]*Entry `json:"placeholders"`
+	// Pictures which were suppressed because they are duplicates of pictures gathered earlier.
+	Duplicates []*Duplicate `json:"duplicates"`
+	// Identifiers (for example accession numbers) which could not be resolved to an item, in the order they were encountered.
+	Unresolved []string `json:"unresolved,omitempty"`
+	// The error, if any, which caused gathering pictures to stop.
+	Error string `json:"error,omitempty"`
+	mu    *sync.Mutex
+}
+
+// NewReport returns a new (empty) `Report` instance.
+func NewReport() *Report {
+
+	r := &Report{
+		Skipped:      make(map[string]*Entry),
+		Placeholders: make(map[string]*Entry),
+		Duplicates:   make([]*Duplicate, 0),
+		mu:           new(sync.Mutex),
+	}
+
+	return r
+}
+
+// a non-200 HTTP status code are expected to return an `api.StatusError`, as API clients do when an `api.Transport` instance is installed.
+// a non-200 HTTP status code are expected to return an `api.StatusError`, as the clients created by `api.NewClientWithQuery` do.
+func ReasonForError(err error) string {
+
+	var url_err *url.Error
+	var status_err *api.StatusError
+
+	switch {
+	case errors.Is(err, response.ErrMissingURITemplate):
+		return REASON_MISSING_TEMPLATE
+	case errors.Is(err, response.ErrMissingSizes), errors.Is(err, response.ErrInvalidSize), errors.Is(err, media.ErrNoSuitableSize):
+		return REASON_NO_SIZES
+	case errors.As(err, &url_err), errors.As(err, &status_err):
+		return REASON_HTTP_ERROR
+	default:
+		return REASON_OTHER
+	}
+}
+
+// AddItems increments the total number of shoebox items processed by 'n'.
+func (r *Report) AddItems(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Items += n
+}
+
+// AddPictures increments the total number of pictures gathered by 'n'.
+func (r *Report) AddPictures(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Pictures += n
+}
+
+// Skip records that the shoebox item 'item_id' was skipped (in whole or in part) for 'reason'.
+func (r *Report) Skip(reason string, item_id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Skipped = addEntry(r.Skipped, reason, item_id)
+}
+
+// Placeholder records that the shoebox item 'item_id' was replaced by a placeholder image for 'reason'.
+func (r *Report) Placeholder(reason string, item_id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Placeholders = addEntry(r.Placeholders, reason, item_id)
+}
+
+// Duplicate records that the picture 'd.URI' was suppressed because it is a duplicate of the picture 'd.OriginalURI'.
+func (r *Report) Duplicate(d *Duplicate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Duplicates = append(r.Duplicates, d)
+}
+
+// AddUnresolved records that 'id' could not be resolved to an item.
+func (r *Report) AddUnresolved(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Unresolved = append(r.Unresolved, id)
+}
+
+// Fail records that gathering pictures was stopped because of 'err'.
+func (r *Report) Fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Error = err.Error()
+}
+
+// addEntry adds 'item_id' to the entry for 'reason' in 'entries' (if it isn't already present).
+func addEntry(entries map[string]*Entry, reason string, item_id int64) map[string]*Entry {
+
+	e, exists := entries[reason]
+
+	if !exists {
+		e = &Entry{
+			ItemIds: make([]int64, 0),
+		}
+		entries[reason] = e
+	}
+
+	idx, found := slices.BinarySearch(e.ItemIds, item_id)
+
+	if !found {
+		e.ItemIds = slices.Insert(e.ItemIds, idx, item_id)
+		e.Count = len(e.ItemIds)
+	}
+
+	return entries
+}
+
+// WriteJSON writes 'r' to 'wr' as JSON.
+func (r *Report) WriteJSON(wr io.Writer) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enc := json.NewEncoder(wr)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(r)
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode report, %w", err)
+	}
+
+	return nil
+}
+
+// WriteFile writes 'r' as JSON to the file at 'path'.
+func (r *Report) WriteFile(path string) error {
+
+	wr, err := os.Create(path)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create %s, %w", path, err)
+	}
+
+	err = r.WriteJSON(wr)
+
+	if err != nil {
+		wr.Close()
+		return err
+	}
+
+	return wr.Close()
+}
+
+// ReadFile returns a new `Report` instance derived from the JSON-encoded report in the file at 'path'.
+func ReadFile(path string) (*Report, error) {
+
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	r := NewReport()
+
+	err = json.Unmarshal(body, r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode %s, %w", path, err)
+	}
+
+	return r, nil
+}
+
+// String returns a human-readable summary of 'r'.
+func (r *Report) String() string {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lines := []string{
+		fmt.Sprintf("Processed %d items and gathered %d pictures.", r.Items, r.Pictures),
+	}
+
+	summarize := func(label string, entries map[string]*Entry) {
+
+		reasons := make([]string, 0)
+
+		for reason := range entries {
+			reasons = append(reasons, reason)
+		}
+
+		sort.Strings(reasons)
+
+		for _, reason := range reasons {
+
+			e := entries[reason]
+
+			ids := make([]string, len(e.ItemIds))
+
+			for idx, id := range e.ItemIds {
+				ids[idx] = fmt.Sprintf("%d", id)
+			}
+
+			lines = append(lines, fmt.Sprintf("%s (%s): %d item(s) %s", label, reason, e.Count, strings.Join(ids, ", ")))
+		}
+	}
+
+	summarize("Skipped", r.Skipped)
+	summarize("Placeholder", r.Placeholders)
+
+	for _, d := range r.Duplicates {
+		lines = append(lines, fmt.Sprintf("Duplicate: item %d (%s) duplicates item %d (%s)", d.ItemId, d.URI, d.OriginalItemId, d.OriginalURI))
+	}
+
+	if len(r.Unresolved) > 0 {
+		lines = append(lines, fmt.Sprintf("Unresolved: %d identifier(s) %s", len(r.Unresolved), strings.Join(r.Unresolved, ", ")))
+	}
+
+	if r.Error != "" {
+		lines = append(lines, fmt.Sprintf("Failed: %s", r.Error))
+	}
+
+	return strings.Join(lines, "\n")
+}
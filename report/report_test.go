@@ -0,0 +1,95 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/media"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+func TestReasonForError(t *testing.T) {
+
+	tests := map[string]error{
+		REASON_MISSING_TEMPLATE: fmt.Errorf("Failed to decode images for object 1, %w", response.ErrMissingURITemplate),
+		REASON_NO_SIZES:         fmt.Errorf("Failed to select size for object 1 image 2, %w", media.ErrNoSuitableSize),
+		REASON_HTTP_ERROR:       fmt.Errorf("Failed to execute API request, %w", &url.Error{Op: "Get", URL: "https://example.com", Err: errors.New("EOF")}),
+		REASON_OTHER:            errors.New("Something went wrong"),
+	}
+
+	for expected, err := range tests {
+
+		reason := ReasonForError(err)
+
+		if reason != expected {
+			t.Fatalf("Unexpected reason for '%v': %s", err, reason)
+		}
+	}
+
+	status_err := &api.StatusError{StatusCode: 500, Status: "500 Internal Server Error"}
+
+	if ReasonForError(fmt.Errorf("Failed to execute method, %w", status_err)) != REASON_HTTP_ERROR {
+		t.Fatalf("Expected API status error to be an HTTP error")
+	}
+}
+
+func TestReport(t *testing.T) {
+
+	r := NewReport()
+	r.AddItems(4)
+	r.AddPictures(3)
+
+	r.Skip(REASON_NO_SIZES, 3)
+	r.Skip(REASON_NO_SIZES, 1)
+	r.Skip(REASON_NO_SIZES, 3)
+	r.Placeholder(REASON_UNSUPPORTED_TYPE, 2)
+	r.AddUnresolved("2015.166.0309")
+
+	path := filepath.Join(t.TempDir(), "report.json")
+
+	err := r.WriteFile(path)
+
+	if err != nil {
+		t.Fatalf("Failed to write report, %v", err)
+	}
+
+	r2, err := ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("Failed to read report, %v", err)
+	}
+
+	if r2.Items != 4 || r2.Pictures != 3 {
+		t.Fatalf("Unexpected totals: %d, %d", r2.Items, r2.Pictures)
+	}
+
+	e, exists := r2.Skipped[REASON_NO_SIZES]
+
+	if !exists || e.Count != 2 || !slices.Equal(e.ItemIds, []int64{1, 3}) {
+		t.Fatalf("Unexpected skipped items: %v", e)
+	}
+
+	e, exists = r2.Placeholders[REASON_UNSUPPORTED_TYPE]
+
+	if !exists || e.Count != 1 {
+		t.Fatalf("Unexpected placeholder items: %v", e)
+	}
+
+	if !slices.Equal(r2.Unresolved, []string{"2015.166.0309"}) {
+		t.Fatalf("Unexpected unresolved identifiers: %v", r2.Unresolved)
+	}
+
+	if r2.String() != r.String() {
+		t.Fatalf("Unexpected summary: %s", r2.String())
+	}
+
+	if !strings.HasPrefix(r.String(), "Processed 4 items and gathered 3 pictures.") {
+		t.Fatalf("Unexpected summary: %s", r.String())
+	}
+}
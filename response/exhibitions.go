@@ -0,0 +1,37 @@
+package response
+
+// ExhibitionInfoResponse defines the response object returned by the `sfomuseum.exhibitions.getInfo` API method.
+type ExhibitionInfoResponse struct {
+	// Exhibition is an `ExhibitionInfo` instance.
+	Exhibition *ExhibitionInfo `json:"exhibition"`
+}
+
+// ExhibitionInfo defines the descriptive details for a SFO Museum exhibition.
+type ExhibitionInfo struct {
+	// The unique identifier for the exhibition.
+	Id int64 `json:"wof:id"`
+	// Title is the title of the exhibition.
+	Title string `json:"title"`
+	// Slug is the short, human-readable, identifier for the exhibition used in its URL.
+	Slug string `json:"slug"`
+	// URL is the sfomuseum.org URL for the exhibition.
+	URL string `json:"url"`
+}
+
+// ExhibitionObjectsResponse defines the response object returned by the `sfomuseum.exhibitions.getObjects` API method.
+type ExhibitionObjectsResponse struct {
+	// Zero or more `ExhibitionObject` instances.
+	Objects []*ExhibitionObject `json:"objects"`
+}
+
+// ExhibitionObject defines an individual object on display in a SFO Museum exhibition.
+type ExhibitionObject struct {
+	// The unique identifier for the object.
+	Id int64 `json:"wof:id"`
+	// Gallery is the name of the gallery the object is on display in.
+	Gallery string `json:"gallery"`
+	// Case is the name of the case the object is on display in.
+	Case string `json:"case"`
+	// Position is the (one-indexed) position of the object in the exhibition's gallery order. Zero if unknown.
+	Position int `json:"position"`
+}
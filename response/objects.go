@@ -0,0 +1,163 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jtacoma/uritemplates"
+)
+
+// ErrMissingURITemplate is returned when an image record does not define a "media:uri_template" property.
+var ErrMissingURITemplate = errors.New("Image record is missing media:uri_template")
+
+// ErrMissingSizes is returned when an image record does not define any "media:properties.sizes" properties.
+var ErrMissingSizes = errors.New("Image record is missing media:properties.sizes")
+
+// ErrInvalidSize is returned when an image size record is missing a secret or an extension.
+var ErrInvalidSize = errors.New("Image size record is missing a secret or extension")
+
+// ObjectImagesResponse defines the response object returned by the `sfomuseum.collection.objects.getImages` API method.
+type ObjectImagesResponse struct {
+	// Zero or more `ObjectImage` instances.
+	Images []*ObjectImage `json:"images"`
+}
+
+// ObjectImage defines an individual image record returned by the `sfomuseum.collection.objects.getImages` API method.
+type ObjectImage struct {
+	// The unique identifier for the image.
+	Id int64 `json:"wof:id"`
+	// URITemplate is the RFC 6570 URI template used to derive the URL for a specific size of the image.
+	URITemplate string `json:"media:uri_template"`
+	// Properties is an `ObjectImageProperties` instance.
+	Properties *ObjectImageProperties `json:"media:properties"`
+}
+
+// ObjectImageProperties defines the media properties for an individual image record.
+type ObjectImageProperties struct {
+	// Sizes is a dictionary of `ObjectImageSize` instances keyed by their size label (for example "o", "k", "b" or "c").
+	Sizes map[string]*ObjectImageSize `json:"sizes"`
+}
+
+// ObjectImageSize defines the details for a specific size of an image.
+type ObjectImageSize struct {
+	// Secret is the secret used to derive the URL for this size of the image.
+	Secret string `json:"secret"`
+	// Extension is the file extension for this size of the image.
+	Extension string `json:"extension"`
+	// Width is the width, in pixels, of this size of the image.
+	Width int `json:"width"`
+	// Height is the height, in pixels, of this size of the image.
+	Height int `json:"height"`
+}
+
+// DecodeObjectImagesResponse decodes the body of a `sfomuseum.collection.objects.getImages` API response in 'r'. Individual
+// image records are not validated so that a single invalid record does not prevent the other images in the response from
+// being used. Callers should use the `ObjectImage.Validate` method to validate each image record.
+func DecodeObjectImagesResponse(r io.Reader) (*ObjectImagesResponse, error) {
+
+	var rsp *ObjectImagesResponse
+
+	dec := json.NewDecoder(r)
+	err := dec.Decode(&rsp)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode images response, %w", err)
+	}
+
+	if rsp == nil {
+		return nil, fmt.Errorf("Failed to decode images response, empty response")
+	}
+
+	return rsp, nil
+}
+
+// Validate ensures that 'im' has a URI template and one or more valid size records.
+func (im *ObjectImage) Validate() error {
+
+	if im.URITemplate == "" {
+		return ErrMissingURITemplate
+	}
+
+	if im.Properties == nil || len(im.Properties.Sizes) == 0 {
+		return ErrMissingSizes
+	}
+
+	for label, sz := range im.Properties.Sizes {
+
+		if sz == nil || sz.Secret == "" || sz.Extension == "" {
+			return fmt.Errorf("%w (%s)", ErrInvalidSize, label)
+		}
+	}
+
+	return nil
+}
+
+// Size returns the `ObjectImageSize` instance for 'label' and a boolean value indicating whether it exists.
+func (im *ObjectImage) Size(label string) (*ObjectImageSize, bool) {
+
+	if im.Properties == nil {
+		return nil, false
+	}
+
+	sz, exists := im.Properties.Sizes[label]
+
+	if !exists || sz == nil {
+		return nil, false
+	}
+
+	return sz, true
+}
+
+// URI returns the URL for the size of 'im' identified by 'label'.
+func (im *ObjectImage) URI(label string) (string, error) {
+
+	sz, exists := im.Size(label)
+
+	if !exists {
+		return "", fmt.Errorf("Image does not have size '%s'", label)
+	}
+
+	uri_t, err := uritemplates.Parse(im.URITemplate)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse URI template, %w", err)
+	}
+
+	vars := map[string]interface{}{
+		"label":     label,
+		"secret":    sz.Secret,
+		"extension": sz.Extension,
+	}
+
+	uri, err := uri_t.Expand(vars)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to expand URI template, %w", err)
+	}
+
+	return uri, nil
+}
+
+// ObjectInfoResponse defines the response object returned by the `sfomuseum.collection.objects.getInfo` API method.
+type ObjectInfoResponse struct {
+	// Object is an `ObjectInfo` instance.
+	Object *ObjectInfo `json:"object"`
+}
+
+// ObjectInfo defines the descriptive details for an object in the SFO Museum Aviation Collection.
+type ObjectInfo struct {
+	// The unique identifier for the object.
+	Id int64 `json:"wof:id"`
+	// Title is the title of the object.
+	Title string `json:"title"`
+	// Date is the date attributed to the object.
+	Date string `json:"date"`
+	// Creditline is the credit line for the object.
+	CreditLine string `json:"creditline"`
+	// AccessionNumber if the object's SFO Museum accession number.
+	AccessionNumber string `json:"accession_number"`
+	// URL is the collection.sfomuseum.org URL for the object.
+	URL string `json:"url"`
+}
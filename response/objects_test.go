@@ -0,0 +1,93 @@
+package response
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeObjectImagesResponse(t *testing.T) {
+
+	body := `{
+	"images": [
+		{
+			"wof:id": 1913663409,
+			"media:uri_template": "https://static.sfomuseum.org/media/191/366/340/9/1913663409_{secret}_{label}.{extension}",
+			"media:properties": {
+				"sizes": {
+					"k": { "secret": "MSM9QjCaQmXnyemSonODPufdrayFWc4a", "extension": "jpg", "width": 2048, "height": 1536 },
+					"c": { "secret": "abc123", "extension": "jpg", "width": 800, "height": 600 }
+				}
+			}
+		}
+	],
+	"page": 1,
+	"pages": 1,
+	"stat": "ok"
+}`
+
+	rsp, err := DecodeObjectImagesResponse(strings.NewReader(body))
+
+	if err != nil {
+		t.Fatalf("Failed to decode images response, %v", err)
+	}
+
+	if len(rsp.Images) != 1 {
+		t.Fatalf("Unexpected number of images: %d", len(rsp.Images))
+	}
+
+	im := rsp.Images[0]
+
+	sz, exists := im.Size("k")
+
+	if !exists {
+		t.Fatalf("Expected 'k' size")
+	}
+
+	if sz.Width != 2048 || sz.Height != 1536 {
+		t.Fatalf("Unexpected dimensions for 'k' size: %dx%d", sz.Width, sz.Height)
+	}
+
+	expected := "https://static.sfomuseum.org/media/191/366/340/9/1913663409_MSM9QjCaQmXnyemSonODPufdrayFWc4a_k.jpg"
+
+	uri, err := im.URI("k")
+
+	if err != nil {
+		t.Fatalf("Failed to derive URI, %v", err)
+	}
+
+	if uri != expected {
+		t.Fatalf("Unexpected URI '%s'", uri)
+	}
+
+	_, err = im.URI("o")
+
+	if err == nil {
+		t.Fatalf("Expected error deriving URI for missing size")
+	}
+}
+
+func TestDecodeObjectImagesResponseInvalid(t *testing.T) {
+
+	tests := map[string]error{
+		`{"images": [ { "wof:id": 1, "media:properties": { "sizes": { "c": { "secret": "a", "extension": "jpg" } } } } ] }`:                                  ErrMissingURITemplate,
+		`{"images": [ { "wof:id": 1, "media:uri_template": "https://example.com/{label}" } ] }`:                                                              ErrMissingSizes,
+		`{"images": [ { "wof:id": 1, "media:uri_template": "https://example.com/{label}", "media:properties": { "sizes": {} } } ] }`:                         ErrMissingSizes,
+		`{"images": [ { "wof:id": 1, "media:uri_template": "https://example.com/{label}", "media:properties": { "sizes": { "c": { "secret": "a" } } } } ] }`: ErrInvalidSize,
+	}
+
+	for body, expected := range tests {
+
+		rsp, err := DecodeObjectImagesResponse(strings.NewReader(body))
+
+		if err != nil {
+			t.Fatalf("Failed to decode %s, %v", body, err)
+		}
+
+		err = rsp.Images[0].Validate()
+
+		if !errors.Is(err, expected) {
+			t.Fatalf("Expected '%v' validating %s, got '%v'", expected, body, err)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package shoebox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/render"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+	"github.com/whosonfirst/go-ioutil"
+)
+
+// CARD_RESOLVER_NAME is the name of the resolver used to generate "card" images for shoebox items which don't have
+// any usable images of their own. It is not a shoebox type name and is never matched against shoebox items directly.
+const CARD_RESOLVER_NAME string = "card"
+
+// CardResolver implements the `Resolver` and `RenderResolver` interfaces for generating typographic "card" images
+// derived from the metadata of shoebox items which don't have any usable images of their own, for example items
+// whose type isn't supported or objects which haven't been photographed yet.
+type CardResolver struct {
+	Resolver
+	api_client client.Client
+	types      map[uint8]string
+	types_load *cardTypesLoad
+	types_mu   *sync.Mutex
+	cards      *sync.Map
+}
+
+// cardTypesLoad is a (possibly in-flight) request for the shoebox type names used by a `CardResolver` which is shared by all
+// the concurrent callers which need them.
+type cardTypesLoad struct {
+	// done is closed once the request has completed.
+	done chan struct{}
+	// types is the map of shoebox type IDs to their names returned by the request.
+	types map[uint8]string
+	// err is the error returned by the request, if any.
+	err error
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := RegisterResolver(ctx, CARD_RESOLVER_NAME, "card", NewCardResolver)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewCardResolver returns a new `CardResolver` instance configured by 'uri' which is expected to take the form of:
+//
+//	card://?{PARAMETERS}
+//
+// Where {PARAMETERS} is any of the API client parameters described in `api.NewClientWithQuery`.
+func NewCardResolver(ctx context.Context, uri string) (Resolver, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	api_client, err := api.NewClientWithQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new client, %w", err)
+	}
+
+	r := &CardResolver{
+		api_client: api_client,
+		types_mu:   new(sync.Mutex),
+		cards:      new(sync.Map),
+	}
+
+	return r, nil
+}
+
+// Resolve returns an iterator containing the (generated) card key for shoebox item 'i'.
+func (r *CardResolver) Resolve(ctx context.Context, i *response.ShoeboxListItem) iter.Seq2[string, error] {
+
+	return func(yield func(string, error) bool) {
+
+		logger := slog.Default()
+		logger = logger.With("item id", i.ItemId)
+		logger = logger.With("type", i.TypeId)
+
+		collected_t := time.Unix(i.Created, 0)
+		collected := fmt.Sprintf("Collected on %s", collected_t.Format("January 02, 2006"))
+
+		type_name, err := r.typeName(ctx, i.TypeId)
+
+		if err != nil {
+			logger.Warn("Failed to derive shoebox type name", "error", err)
+		}
+
+		opts := &render.CardOptions{
+			Title: fmt.Sprintf("Shoebox item %d", i.ItemId),
+			Lines: []string{
+				fmt.Sprintf("Type: %s", type_name),
+				"",
+				collected,
+			},
+		}
+
+		if type_name == "" {
+			opts.Lines[0] = fmt.Sprintf("Type: %d", i.TypeId)
+		}
+
+		if type_name == "object" {
+
+			obj, err := r.getObjectInfo(ctx, i.ItemId)
+
+			if err != nil {
+				logger.Warn("Failed to retrieve object info, using default card", "error", err)
+			} else {
+
+				opts.Title = obj.Title
+				opts.Lines = []string{
+					obj.Date,
+					"",
+					obj.AccessionNumber,
+					obj.URL,
+					"",
+					collected,
+				}
+			}
+		}
+
+		// Items which were not collected in a shoebox (for example the results of a collection search) don't have a date
+
+		if i.Created == 0 {
+			opts.Lines = opts.Lines[:len(opts.Lines)-2]
+		}
+
+		r.cards.Store(i.Id, opts)
+
+		image_uri := keys.Encode(&keys.ShoeboxKey{
+			URI:     GeneratedKey("card", i.Id),
+			Label:   "card",
+			Id:      i.Id,
+			ItemId:  i.ItemId,
+			Created: i.Created,
+			Type:    type_name,
+		})
+
+		yield(image_uri, nil)
+	}
+}
+
+// Render returns an `io.ReadSeekCloser` instance containing the (PNG-encoded) card identified by 'key'. Cards are
+// derived from details collected by the `Resolve` method so 'key' must have been produced by the same `CardResolver` instance.
+func (r *CardResolver) Render(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+
+	_, id, err := parseGeneratedKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	v, exists := r.cards.Load(id)
+
+	if !exists {
+		return nil, fmt.Errorf("Unknown card %d", id)
+	}
+
+	im, err := render.Card(v.(*render.CardOptions))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to render card %d, %w", id, err)
+	}
+
+	body, err := render.EncodePNG(im)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NewReadSeekCloser(bytes.NewReader(body))
+}
+
+// Caption returns the caption text for the card identified by 'key'. Since the details for a shoebox item are
+// drawn on the card itself the caption only contains the date the item was collected, if known.
+func (r *CardResolver) Caption(ctx context.Context, key string) (string, error) {
+
+	collected_t, err := itemCollected(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	if collected_t.Unix() == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf("Collected on %s", collected_t.Format("January 02, 2006")), nil
+}
+
+// Pixels returns the number of pixels (width × height) for the card identified by 'key'.
+func (r *CardResolver) Pixels(key string) (int64, bool) {
+
+	if !IsGeneratedKey(key) {
+		return 0, false
+	}
+
+	return int64(render.DEFAULT_WIDTH) * int64(render.DEFAULT_HEIGHT), true
+}
+
+// typeName returns the shoebox type name for 'type_id'.
+func (r *CardResolver) typeName(ctx context.Context, type_id uint8) (string, error) {
+
+	// The lock is only held to check for (or start) a request for the type names, not while it is performed, so
+	// concurrent callers wait for the same request and callers whose context is cancelled don't block the others
+
+	r.types_mu.Lock()
+
+	if r.types != nil {
+		name := r.types[type_id]
+		r.types_mu.Unlock()
+		return name, nil
+	}
+
+	load := r.types_load
+	leader := load == nil
+
+	if leader {
+		load = &cardTypesLoad{
+			done: make(chan struct{}),
+		}
+
+		r.types_load = load
+	}
+
+	r.types_mu.Unlock()
+
+	if leader {
+
+		types_map, err := TypesMap(ctx, r.api_client)
+
+		if err == nil {
+
+			load.types = make(map[uint8]string)
+
+			for name, id := range types_map {
+				load.types[id] = name
+			}
+		}
+
+		load.err = err
+
+		r.types_mu.Lock()
+
+		// Failed requests are not cached so the next caller will try again
+
+		if err == nil {
+			r.types = load.types
+		}
+
+		r.types_load = nil
+		r.types_mu.Unlock()
+
+		close(load.done)
+	}
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-load.done:
+		// pass
+	}
+
+	if load.err != nil {
+		return "", load.err
+	}
+
+	return load.types[type_id], nil
+}
+
+// getObjectInfo returns the `response.ObjectInfo` instance for 'object_id' using the `sfomuseum.collection.objects.getInfo` API method.
+func (r *CardResolver) getObjectInfo(ctx context.Context, object_id int64) (*response.ObjectInfo, error) {
+
+	args := &url.Values{}
+	args.Set("method", "sfomuseum.collection.objects.getInfo")
+	args.Set("object_id", strconv.FormatInt(object_id, 10))
+
+	rsp, err := r.api_client.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to execute sfomuseum.collection.objects.getInfo method, %w", err)
+	}
+
+	defer rsp.Close()
+
+	var obj_rsp *response.ObjectInfoResponse
+
+	dec := json.NewDecoder(rsp)
+	err = dec.Decode(&obj_rsp)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal object response, %w", err)
+	}
+
+	if obj_rsp.Object == nil {
+		return nil, fmt.Errorf("Missing object %d", object_id)
+	}
+
+	return obj_rsp.Object, nil
+}
@@ -0,0 +1,59 @@
+package shoebox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GENERATED_SCHEME is the URI scheme used by the keys of images which are generated by a resolver rather than
+// being retrieved from a remote host.
+const GENERATED_SCHEME string = "shoebox"
+
+// RenderResolver is an optional interface implemented by resolvers that generate (render) their own images.
+type RenderResolver interface {
+	// Render returns an `io.ReadSeekCloser` instance for the (PNG-encoded) image identified by a key produced by
+	// the `Resolve` method. Since the URL fragment of a key may be removed before it is passed to a bucket's
+	// `NewReader` method implementations should not depend on it being present.
+	Render(context.Context, string) (io.ReadSeekCloser, error)
+}
+
+// GeneratedKey returns the key for an image generated by the resolver identified by 'label' for the item
+// identified by 'id'. Keys take the form of "shoebox://{LABEL}/{ID}.png".
+func GeneratedKey(label string, id int64) string {
+	return fmt.Sprintf("%s://%s/%d.png", GENERATED_SCHEME, label, id)
+}
+
+// IsGeneratedKey returns a boolean value indicating whether 'key' identifies an image generated by a resolver.
+func IsGeneratedKey(key string) bool {
+	return strings.HasPrefix(key, GENERATED_SCHEME+"://")
+}
+
+// parseGeneratedKey returns the resolver label and item ID encoded in 'key'.
+func parseGeneratedKey(key string) (string, int64, error) {
+
+	if !IsGeneratedKey(key) {
+		return "", 0, fmt.Errorf("Invalid key")
+	}
+
+	u, err := url.Parse(key)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to parse key, %w", err)
+	}
+
+	fname := filepath.Base(u.Path)
+	str_id := strings.TrimSuffix(fname, filepath.Ext(fname))
+
+	id, err := strconv.ParseInt(str_id, 10, 64)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to parse ID for key, %w", err)
+	}
+
+	return u.Host, id, nil
+}
@@ -0,0 +1,199 @@
+package shoebox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-wordwrap"
+	"github.com/rainycape/unidecode"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/media"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+)
+
+// InstagramResolver implements the `Resolver` interface for SFO Museum Instagram posts in a shoebox.
+type InstagramResolver struct {
+	Resolver
+	api_client client.Client
+	phashes    *sync.Map
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := RegisterResolver(ctx, "instagram", "ig", NewInstagramResolver)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewInstagramResolver returns a new `InstagramResolver` instance configured by 'uri' which is expected to take the form of:
+//
+//	instagram://?{PARAMETERS}
+//
+// Where {PARAMETERS} is any of the API client parameters described in `api.NewClientWithQuery`.
+func NewInstagramResolver(ctx context.Context, uri string) (Resolver, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	api_client, err := api.NewClientWithQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new client, %w", err)
+	}
+
+	r := &InstagramResolver{
+		api_client: api_client,
+		phashes:    new(sync.Map),
+	}
+
+	return r, nil
+}
+
+// Resolve returns an iterator containing the image URI for the Instagram post associated with shoebox item 'i'.
+func (r *InstagramResolver) Resolve(ctx context.Context, i *response.ShoeboxListItem) iter.Seq2[string, error] {
+
+	return func(yield func(string, error) bool) {
+
+		str_id := strconv.FormatInt(i.ItemId, 10)
+
+		ig_post, err := r.getInfo(ctx, str_id)
+
+		if err != nil {
+			yield("", err)
+			return
+		}
+
+		// SFOMuseumImage should not be considered stable yet and may be replaced/removed
+
+		// Note the URL fragment. This is necessary (for the time being) since the IG image
+		// URLs don't have any pointers or references to the SFO Museum post ID. We append
+		// that info in the URL fragment here and then dereference it in the Caption method.
+
+		image_uri := keys.Encode(&keys.ShoeboxKey{
+			URI:     ig_post.SFOMuseumImage,
+			Label:   "ig",
+			Id:      ig_post.WhosOnFirstId,
+			ItemId:  i.ItemId,
+			Created: i.Created,
+			Type:    "instagram",
+		})
+
+		if ig_post.PerceptualHash != "" {
+
+			phash, err := media.ParsePerceptualHash(ig_post.PerceptualHash)
+
+			if err != nil {
+				slog.Warn("Failed to parse perceptual hash for Instagram post", "post", ig_post.WhosOnFirstId, "error", err)
+			} else {
+				r.phashes.Store(image_uri, phash)
+			}
+		}
+
+		yield(image_uri, nil)
+	}
+}
+
+// Caption returns the caption text for the Instagram post image identified by 'key'.
+func (r *InstagramResolver) Caption(ctx context.Context, key string) (string, error) {
+
+	// All of the key details are assigned in the Resolve method
+
+	k, err := keys.Parse(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	collected_t := time.Unix(k.Created, 0)
+	post_id := strconv.FormatInt(k.Id, 10)
+
+	ig_post, err := r.getInfo(ctx, post_id)
+
+	if err != nil {
+		return "", err
+	}
+
+	post_t := time.Unix(ig_post.Taken, 0)
+
+	// This shouldn't be necessary (in an ideal world) but the
+	// aaronland/go-picturebook package uses HTMLBasicNew() for
+	// adding text and it has... issues.
+	ig_body := unidecode.Unidecode(ig_post.Caption.Excerpt)
+
+	// Maybe make this value configurable in the shoebox:// caption URI?
+	ig_body = wordwrap.WrapString(ig_body, 145)
+
+	text := []string{
+		fmt.Sprintf(`"%s"`, ig_body),
+		fmt.Sprintf("This was posted to the SFO Museum Instagram account on %s", post_t.Format("January 02, 2006")),
+		"",
+		fmt.Sprintf("https://millsfield.sfomuseum.org/instagram/%s", post_id),
+	}
+
+	// Posts which were not collected in a shoebox (for example those gathered by the instagram:// bucket) don't have a created date
+
+	if k.Created > 0 {
+		text = append(text, fmt.Sprintf("Collected on %s", collected_t.Format("January 02, 2006")))
+	}
+
+	str_text := strings.Join(text, "\n")
+	return str_text, nil
+}
+
+// PerceptualHash returns the perceptual hash for the Instagram post image identified by 'key', as recorded by SFO Museum.
+func (r *InstagramResolver) PerceptualHash(key string) (uint64, bool) {
+
+	v, exists := r.phashes.Load(key)
+
+	if !exists {
+		return 0, false
+	}
+
+	return v.(uint64), true
+}
+
+// getInfo returns the `response.InstagramPost` instance for 'post_id' using the `sfomuseum.millsfield.instagram.getInfo` API method.
+func (r *InstagramResolver) getInfo(ctx context.Context, post_id string) (*response.InstagramPost, error) {
+
+	ig_args := &url.Values{}
+	ig_args.Set("method", "sfomuseum.millsfield.instagram.getInfo")
+	ig_args.Set("post_id", post_id)
+
+	ig_rsp, err := r.api_client.ExecuteMethod(ctx, http.MethodGet, ig_args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to execute sfomuseum.millsfield.instagram.getInfo method, %w", err)
+	}
+
+	defer ig_rsp.Close()
+
+	var ig_post_rsp *response.InstagramPostResponse
+
+	dec := json.NewDecoder(ig_rsp)
+	err = dec.Decode(&ig_post_rsp)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal IG post response, %w", err)
+	}
+
+	return ig_post_rsp.Post, nil
+}
@@ -0,0 +1,19 @@
+package shoebox
+
+import (
+	"time"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+)
+
+// itemCollected returns the date that the shoebox item associated with 'key' was collected.
+func itemCollected(key string) (time.Time, error) {
+
+	k, err := keys.Parse(key)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(k.Created, 0), nil
+}
@@ -0,0 +1,373 @@
+package shoebox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/api"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/media"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+)
+
+var re_image_filename = regexp.MustCompile(`^(\d+)_[^_]+_[^_.]+\.\w+$`)
+
+// ObjectResolver implements the `Resolver` interface for SFO Museum Aviation Collection objects in a shoebox.
+type ObjectResolver struct {
+	Resolver
+	api_client client.Client
+	sizes      *media.SizePreference
+	images     *media.ImageSelection
+	// pixels is a map of the number of pixels for the images that have been resolved, keyed by image URI (without a URL fragment).
+	pixels *sync.Map
+	// thumbnails is a map of the URIs of smaller versions of the images that have been resolved, keyed by image URI (without a URL fragment).
+	thumbnails *sync.Map
+	// exhibitions is a map of exhibition titles keyed by exhibition ID.
+	exhibitions *sync.Map
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := RegisterResolver(ctx, "object", "o", NewObjectResolver)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewObjectResolver returns a new `ObjectResolver` instance configured by 'uri' which is expected to take the form of:
+//
+//	object://?{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - Any of the API client parameters described in `api.NewClientWithQuery`.
+// - `?images={MODE}` Which images to include for each object. See `media.NewImageSelection` for details.
+// - Any of the size preference parameters described in `media.NewSizePreference`.
+func NewObjectResolver(ctx context.Context, uri string) (Resolver, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	api_client, err := api.NewClientWithQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new client, %w", err)
+	}
+
+	sizes, err := media.NewSizePreference(q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive size preference, %w", err)
+	}
+
+	images, err := media.NewImageSelection(q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive image selection, %w", err)
+	}
+
+	r := &ObjectResolver{
+		api_client:  api_client,
+		sizes:       sizes,
+		images:      images,
+		pixels:      new(sync.Map),
+		thumbnails:  new(sync.Map),
+		exhibitions: new(sync.Map),
+	}
+
+	return r, nil
+}
+
+// Resolve returns an iterator of image URIs for the object associated with shoebox item 'i'.
+func (r *ObjectResolver) Resolve(ctx context.Context, i *response.ShoeboxListItem) iter.Seq2[string, error] {
+
+	// https://api.sfomuseum.org/methods/sfomuseum.collection.objects.getImages
+
+	return func(yield func(string, error) bool) {
+
+		str_id := strconv.FormatInt(i.ItemId, 10)
+
+		im_args := &url.Values{}
+		im_args.Set("method", "sfomuseum.collection.objects.getImages")
+		im_args.Set("object_id", str_id)
+
+		seen := 0
+
+		for im_r, err := range client.ExecuteMethodPaginatedWithClient(ctx, r.api_client, http.MethodGet, im_args) {
+
+			if err != nil {
+				yield("", err)
+				return
+			}
+
+			im_rsp, err := response.DecodeObjectImagesResponse(im_r)
+
+			if err != nil {
+
+				if !yield("", fmt.Errorf("Failed to decode images for object %d, %w", i.ItemId, err)) {
+					return
+				}
+
+				continue
+			}
+
+			for _, im := range im_rsp.Images {
+
+				if r.images.Done(seen) {
+					return
+				}
+
+				// Invalid image records are reported individually so that the other images for the object can still be used
+
+				err := im.Validate()
+
+				if err != nil {
+
+					if !yield("", fmt.Errorf("Invalid image record for object %d image %d, %w", i.ItemId, im.Id, err)) {
+						return
+					}
+
+					continue
+				}
+
+				label, err := r.sizes.Select(im)
+
+				if err != nil {
+
+					if !yield("", fmt.Errorf("Failed to select size for object %d image %d, %w", i.ItemId, im.Id, err)) {
+						return
+					}
+
+					continue
+				}
+
+				im_uri, err := im.URI(label)
+
+				if err != nil {
+
+					if !yield("", fmt.Errorf("Failed to derive URI for object %d image %d, %w", i.ItemId, im.Id, err)) {
+						return
+					}
+
+					continue
+				}
+
+				image_uri := keys.Encode(&keys.ShoeboxKey{
+					URI:     im_uri,
+					Label:   "o",
+					Id:      i.Id,
+					ItemId:  i.ItemId,
+					Created: i.Created,
+					ImageId: im.Id,
+					Size:    label,
+					Type:    "object",
+				})
+
+				sz, _ := im.Size(label)
+				r.pixels.Store(im_uri, int64(sz.Width)*int64(sz.Height))
+
+				// Perceptual hashes are derived from a smaller version of the image, if there is one
+
+				thumb_label, ok := media.SelectThumbnail(im, label, media.PHASH_THUMBNAIL_SIZE)
+
+				if ok && thumb_label != label {
+
+					thumb_uri, err := im.URI(thumb_label)
+
+					if err == nil {
+						r.thumbnails.Store(im_uri, thumb_uri)
+					}
+				}
+
+				if !yield(image_uri, nil) {
+					return
+				}
+
+				seen += 1
+			}
+
+			if r.images.Done(seen) {
+				return
+			}
+		}
+	}
+}
+
+// Caption returns the caption text for the object image identified by 'key'.
+func (r *ObjectResolver) Caption(ctx context.Context, key string) (string, error) {
+
+	// https://api.sfomuseum.org/methods/sfomuseum.collection.images.getCaption
+
+	k, err := keys.Parse(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	collected_t := time.Unix(k.Created, 0)
+
+	image_id := strconv.FormatInt(k.ImageId, 10)
+
+	if k.ImageId == 0 {
+
+		// Legacy keys don't include the image ID so derive it from the image filename
+		// which takes the form of "{IMAGE_ID}_{SECRET}_{LABEL}.{EXTENSION}"
+
+		m := re_image_filename.FindStringSubmatch(filepath.Base(k.URI))
+
+		if m == nil {
+			return "", fmt.Errorf("Unable to derive image ID from %s", key)
+		}
+
+		image_id = m[1]
+	}
+
+	args := &url.Values{}
+	args.Set("method", "sfomuseum.collection.images.getCaption")
+	args.Set("image_id", image_id)
+
+	caption_r, err := r.api_client.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to get caption for image %s, %w", image_id, err)
+	}
+
+	defer caption_r.Close()
+
+	var caption_rsp *response.ImageCaptionResponse
+
+	dec := json.NewDecoder(caption_r)
+	err = dec.Decode(&caption_rsp)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode caption for image %s, %w", image_id, err)
+	}
+
+	str_caption := caption_rsp.Caption.String()
+
+	// Objects which were not collected in a shoebox (for example the results of a collection search) don't have a date
+
+	if k.Exhibition != 0 {
+		str_caption = fmt.Sprintf("%s\n%s", str_caption, r.onDisplay(ctx, k))
+	}
+
+	if k.Created > 0 {
+		str_caption = fmt.Sprintf("%s\nCollected on %s", str_caption, collected_t.Format("January 02, 2006"))
+	}
+
+	return str_caption, nil
+}
+
+// onDisplay returns a description of where the object image identified by 'k' is on display, derived from the exhibition, gallery
+// and case encoded in its key. The title of the exhibition is retrieved using the `sfomuseum.exhibitions.getInfo` API method.
+func (r *ObjectResolver) onDisplay(ctx context.Context, k *keys.ShoeboxKey) string {
+
+	location := []string{
+		fmt.Sprintf("exhibition %d", k.Exhibition),
+	}
+
+	title, err := r.exhibitionTitle(ctx, k.Exhibition)
+
+	if err != nil {
+		slog.Warn("Failed to retrieve exhibition title", "exhibition", k.Exhibition, "error", err)
+	} else {
+		location[0] = fmt.Sprintf("the \"%s\" exhibition", title)
+	}
+
+	for _, v := range []string{k.Gallery, k.Case} {
+
+		if v != "" {
+			location = append(location, v)
+		}
+	}
+
+	return fmt.Sprintf("On display in %s", strings.Join(location, ", "))
+}
+
+// exhibitionTitle returns the title of the exhibition whose ID is 'exhibition_id' using the `sfomuseum.exhibitions.getInfo` API method.
+func (r *ObjectResolver) exhibitionTitle(ctx context.Context, exhibition_id int64) (string, error) {
+
+	v, exists := r.exhibitions.Load(exhibition_id)
+
+	if exists {
+		return v.(string), nil
+	}
+
+	args := &url.Values{}
+	args.Set("method", "sfomuseum.exhibitions.getInfo")
+	args.Set("exhibition_id", strconv.FormatInt(exhibition_id, 10))
+
+	rsp, err := r.api_client.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to execute sfomuseum.exhibitions.getInfo method, %w", err)
+	}
+
+	defer rsp.Close()
+
+	var info_rsp *response.ExhibitionInfoResponse
+
+	dec := json.NewDecoder(rsp)
+	err = dec.Decode(&info_rsp)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to unmarshal exhibition response, %w", err)
+	}
+
+	if info_rsp.Exhibition == nil || info_rsp.Exhibition.Title == "" {
+		return "", fmt.Errorf("Missing exhibition %d", exhibition_id)
+	}
+
+	r.exhibitions.Store(exhibition_id, info_rsp.Exhibition.Title)
+	return info_rsp.Exhibition.Title, nil
+}
+
+// Pixels returns the number of pixels (width × height) for the image identified by 'key'.
+func (r *ObjectResolver) Pixels(key string) (int64, bool) {
+
+	// Keys are stored without their URL fragment so that pixels are still known for keys which have had
+	// additional context (for example the exhibition an object is on display in) added to their fragment.
+
+	uri, _, _ := strings.Cut(key, "#")
+
+	v, exists := r.pixels.Load(uri)
+
+	if !exists {
+		return 0, false
+	}
+
+	return v.(int64), true
+}
+
+// Thumbnail returns the URI of a smaller version of the image identified by 'key', with the same aspect ratio and at least
+// `media.PHASH_THUMBNAIL_SIZE` pixels wide and tall, suitable for deriving its perceptual hash.
+func (r *ObjectResolver) Thumbnail(key string) (string, bool) {
+
+	uri, _, _ := strings.Cut(key, "#")
+
+	v, exists := r.thumbnails.Load(uri)
+
+	if !exists {
+		return "", false
+	}
+
+	return v.(string), true
+}
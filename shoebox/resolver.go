@@ -0,0 +1,196 @@
+// package shoebox provides a registry of resolvers for deriving images, and their captions, from the different types of items in a SFO Museum "shoebox".
+package shoebox
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aaronland/go-roster"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+// Resolver is an interface for deriving images, and their captions, from a specific type of SFO Museum shoebox item.
+type Resolver interface {
+	// Resolve returns an iterator of keys (image URIs) for a shoebox item.
+	Resolve(context.Context, *response.ShoeboxListItem) iter.Seq2[string, error]
+	// Caption returns the caption text for a key produced by the `Resolve` method.
+	Caption(context.Context, string) (string, error)
+}
+
+// PixelsResolver is an optional interface implemented by resolvers that know the dimensions of the images they resolve.
+type PixelsResolver interface {
+	// Pixels returns the number of pixels (width × height) for the image identified by a key produced by the
+	// `Resolve` method and a boolean value indicating whether that number is known.
+	Pixels(string) (int64, bool)
+}
+
+// PerceptualHashResolver is an optional interface implemented by resolvers that know the perceptual hashes of the images they resolve.
+type PerceptualHashResolver interface {
+	// PerceptualHash returns the 64-bit perceptual hash (see `media.PerceptualHash`) for the image identified by a key produced
+	// by the `Resolve` method and a boolean value indicating whether that hash is known.
+	PerceptualHash(string) (uint64, bool)
+}
+
+// ThumbnailResolver is an optional interface implemented by resolvers that know the URIs of smaller versions of the images they resolve.
+type ThumbnailResolver interface {
+	// Thumbnail returns the URI of a smaller version, with the same aspect ratio, of the image identified by a key produced by the
+	// `Resolve` method and a boolean value indicating whether that URI is known.
+	Thumbnail(string) (string, bool)
+}
+
+// ResolverInitializationFunc is a function defined by individual resolver packages and used to create an instance of that resolver.
+type ResolverInitializationFunc func(ctx context.Context, uri string) (Resolver, error)
+
+var resolver_roster roster.Roster
+
+// resolver_labels is a lookup table mapping the labels used to identify keys to their resolver (shoebox type) names.
+var resolver_labels = new(sync.Map)
+
+// RegisterResolver registers 'name', which is expected to be a shoebox type name as returned by the `sfomuseum.you.shoebox.typesMap`
+// API method, as a key pointing to 'init_func' in an internal lookup table used to create new `Resolver` instances by the `NewResolver`
+// method. 'label' is the (unique) label used in the URL fragment of the keys produced by the resolver and is used to determine which
+// resolver should be used to derive captions for those keys.
+func RegisterResolver(ctx context.Context, name string, label string, init_func ResolverInitializationFunc) error {
+
+	err := ensureResolverRoster()
+
+	if err != nil {
+		return err
+	}
+
+	_, exists := resolver_labels.LoadOrStore(label, strings.ToLower(name))
+
+	if exists {
+		return fmt.Errorf("Label '%s' has already been registered", label)
+	}
+
+	return resolver_roster.Register(ctx, name, init_func)
+}
+
+func ensureResolverRoster() error {
+
+	if resolver_roster == nil {
+
+		r, err := roster.NewDefaultRoster()
+
+		if err != nil {
+			return err
+		}
+
+		resolver_roster = r
+	}
+
+	return nil
+}
+
+// NewResolver returns a new `Resolver` instance configured by 'uri'. The value of 'uri' is parsed as a `url.URL` and its scheme
+// is used as the key for a corresponding `ResolverInitializationFunc` function used to instantiate the new `Resolver`. It is
+// assumed that the scheme (and initialization function) have been registered by the `RegisterResolver` method.
+func NewResolver(ctx context.Context, uri string) (Resolver, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ensureResolverRoster()
+
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := u.Scheme
+
+	i, err := resolver_roster.Driver(ctx, scheme)
+
+	if err != nil {
+		return nil, err
+	}
+
+	init_func := i.(ResolverInitializationFunc)
+	return init_func(ctx, uri)
+}
+
+// NewResolvers returns a dictionary of `Resolver` instances, keyed by their (lower-cased) names, for all the registered
+// resolvers. Each resolver is created with the URI "{NAME}://?{QUERY}" where {QUERY} is the encoded version of 'q'.
+func NewResolvers(ctx context.Context, q url.Values) (map[string]Resolver, error) {
+
+	resolvers := make(map[string]Resolver)
+
+	for _, name := range ResolverNames() {
+
+		u := url.URL{}
+		u.Scheme = name
+		u.RawQuery = q.Encode()
+
+		r, err := NewResolver(ctx, u.String())
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create %s resolver, %w", name, err)
+		}
+
+		resolvers[name] = r
+	}
+
+	return resolvers, nil
+}
+
+// ResolverNames returns the (lower-cased) list of names that have been registered.
+func ResolverNames() []string {
+
+	ctx := context.Background()
+	names := []string{}
+
+	err := ensureResolverRoster()
+
+	if err != nil {
+		return names
+	}
+
+	for _, dr := range resolver_roster.Drivers(ctx) {
+		names = append(names, strings.ToLower(dr))
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// ResolverNameForKey returns the (lower-cased) name of the resolver that produced 'key' and a boolean value indicating
+// whether it could be determined. The resolver is derived from the URL fragment of 'key' or, for keys without a fragment,
+// from the host of generated keys.
+func ResolverNameForKey(key string) (string, bool) {
+
+	var label string
+
+	k, err := keys.Parse(key)
+
+	if err == nil {
+		label = k.Label
+	} else {
+
+		if !IsGeneratedKey(key) {
+			return "", false
+		}
+
+		label, _, err = parseGeneratedKey(key)
+
+		if err != nil {
+			return "", false
+		}
+	}
+
+	v, exists := resolver_labels.Load(label)
+
+	if !exists {
+		return "", false
+	}
+
+	return v.(string), true
+}
@@ -0,0 +1,114 @@
+package shoebox
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+)
+
+type testResolver struct {
+	Resolver
+}
+
+func (r *testResolver) Resolve(ctx context.Context, i *response.ShoeboxListItem) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {}
+}
+
+func (r *testResolver) Caption(ctx context.Context, key string) (string, error) {
+	return "test", nil
+}
+
+func newTestResolver(ctx context.Context, uri string) (Resolver, error) {
+	return &testResolver{}, nil
+}
+
+func TestRegisterResolver(t *testing.T) {
+
+	ctx := context.Background()
+
+	err := RegisterResolver(ctx, "testing", "t", newTestResolver)
+
+	if err != nil {
+		t.Fatalf("Failed to register resolver, %v", err)
+	}
+
+	err = RegisterResolver(ctx, "testing2", "t", newTestResolver)
+
+	if err == nil {
+		t.Fatalf("Expected duplicate label to fail")
+	}
+
+	names := ResolverNames()
+
+	for _, n := range []string{"instagram", "object", "testing"} {
+
+		if !slices.Contains(names, n) {
+			t.Fatalf("Expected '%s' to be registered, %v", n, names)
+		}
+	}
+
+	q := url.Values{}
+	q.Set("token", "TOKEN")
+
+	resolvers, err := NewResolvers(ctx, q)
+
+	if err != nil {
+		t.Fatalf("Failed to create resolvers, %v", err)
+	}
+
+	_, ok := resolvers["testing"].(*testResolver)
+
+	if !ok {
+		t.Fatalf("Unexpected resolver for 'testing'")
+	}
+
+	_, ok = resolvers["object"].(PixelsResolver)
+
+	if !ok {
+		t.Fatalf("Expected object resolver to implement PixelsResolver")
+	}
+}
+
+func TestResolverNameForKey(t *testing.T) {
+
+	tests := map[string]string{
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#o:1:101:1704182400":                                                        "object",
+		"https://static.sfomuseum.org/media/2010_ig_b.jpg#ig:2010:201:1704268800":                                                   "instagram",
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=o;id=1;item=101;created=1704182400;image=1011;size=k;type=object": "object",
+		"shoebox://card/4.png#v1;label=card;id=4;item=301;created=1704441600;type=flight":                                           "card",
+	}
+
+	for key, expected := range tests {
+
+		name, ok := ResolverNameForKey(key)
+
+		if !ok {
+			t.Fatalf("Failed to derive resolver name for %s", key)
+		}
+
+		if name != expected {
+			t.Fatalf("Unexpected resolver name for %s: %s", key, name)
+		}
+	}
+
+	invalid := []string{
+		"https://static.sfomuseum.org/media/1011_k_k.jpg",
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#x:1:101:1704182400",
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#o:1:101",
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#v1;label=x;id=1;item=101;created=1704182400",
+		"https://static.sfomuseum.org/media/1011_k_k.jpg#v2;label=o;id=1;item=101;created=1704182400",
+	}
+
+	for _, key := range invalid {
+
+		_, ok := ResolverNameForKey(key)
+
+		if ok {
+			t.Fatalf("Expected %s to fail", key)
+		}
+	}
+}
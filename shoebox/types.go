@@ -0,0 +1,38 @@
+package shoebox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/sfomuseum/go-picturebook-sfomuseum/response"
+	"github.com/sfomuseum/go-sfomuseum-api/v2/client"
+)
+
+// TypesMap returns a dictionary of shoebox type names and their numeric identifiers using the `sfomuseum.you.shoebox.typesMap` API method.
+func TypesMap(ctx context.Context, api_client client.Client) (map[string]uint8, error) {
+
+	args := &url.Values{}
+	args.Set("method", "sfomuseum.you.shoebox.typesMap")
+
+	r, err := api_client.ExecuteMethod(ctx, http.MethodGet, args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to execute sfomuseum.you.shoebox.typesMap method, %w", err)
+	}
+
+	defer r.Close()
+
+	var types_map_rsp *response.ShoeboxTypesMapResponse
+
+	dec := json.NewDecoder(r)
+	err = dec.Decode(&types_map_rsp)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal types map response, %w", err)
+	}
+
+	return types_map_rsp.Types, nil
+}
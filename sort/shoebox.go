@@ -0,0 +1,144 @@
+// package sort provides implementations of the `aaronland/go-picturebook/sort.Sorter` interface for use with images in a SFO Museum "shoebox".
+package sort
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	pb_bucket "github.com/aaronland/go-picturebook/bucket"
+	"github.com/aaronland/go-picturebook/picture"
+	pb_sort "github.com/aaronland/go-picturebook/sort"
+	"github.com/sfomuseum/go-picturebook-sfomuseum/keys"
+)
+
+// ShoeboxSorter implements the `aaronland/go-picturebook/sort.Sorter` interface to sort images in a SFO Museum "shoebox"
+// by the date they were collected, ensuring that all the images for a given shoebox item (for example the front and
+// back of a postcard) are kept together in the order they were gathered.
+type ShoeboxSorter struct {
+	pb_sort.Sorter
+	descending bool
+}
+
+// shoeboxGroup is a collection of `picture.PictureBookPicture` instances associated with the same shoebox item.
+type shoeboxGroup struct {
+	created  int64
+	offset   int
+	pictures []*picture.PictureBookPicture
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := pb_sort.RegisterSorter(ctx, "shoebox", NewShoeboxSorter)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewShoeboxSorter returns a new `ShoeboxSorter` instance configured by 'uri' which is expected to take the form of:
+//
+//	shoebox://?{PARAMETERS}
+//
+// Where {PARAMETERS} is:
+// - `?order={ORDER}` The order in which shoebox items should be sorted. Valid options are "asc" or "desc". Default is "asc".
+func NewShoeboxSorter(ctx context.Context, uri string) (pb_sort.Sorter, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	s := &ShoeboxSorter{}
+
+	switch strings.ToLower(q.Get("order")) {
+	case "", "asc":
+		// pass
+	case "desc":
+		s.descending = true
+	default:
+		return nil, fmt.Errorf("Invalid or unsupported ?order= parameter")
+	}
+
+	return s, nil
+}
+
+// Sort sorts 'pictures' by the date their shoebox items were collected keeping all the images for a given shoebox item together.
+// Pictures whose keys do not contain shoebox item details are appended to the end of the list in the order they were gathered.
+func (s *ShoeboxSorter) Sort(ctx context.Context, b pb_bucket.Bucket, pictures []*picture.PictureBookPicture) ([]*picture.PictureBookPicture, error) {
+
+	lookup := make(map[string]*shoeboxGroup)
+	groups := make([]*shoeboxGroup, 0)
+
+	others := make([]*picture.PictureBookPicture, 0)
+
+	for idx, pic := range pictures {
+
+		group_key, created, ok := shoeboxItem(pic.Source)
+
+		if !ok {
+			others = append(others, pic)
+			continue
+		}
+
+		g, exists := lookup[group_key]
+
+		if !exists {
+
+			g = &shoeboxGroup{
+				created:  created,
+				offset:   idx,
+				pictures: make([]*picture.PictureBookPicture, 0),
+			}
+
+			lookup[group_key] = g
+			groups = append(groups, g)
+		}
+
+		g.pictures = append(g.pictures, pic)
+	}
+
+	slices.SortStableFunc(groups, func(a *shoeboxGroup, b *shoeboxGroup) int {
+
+		if a.created != b.created {
+
+			if s.descending {
+				return cmp.Compare(b.created, a.created)
+			}
+
+			return cmp.Compare(a.created, b.created)
+		}
+
+		return a.offset - b.offset
+	})
+
+	sorted := make([]*picture.PictureBookPicture, 0, len(pictures))
+
+	for _, g := range groups {
+		sorted = append(sorted, g.pictures...)
+	}
+
+	sorted = append(sorted, others...)
+	return sorted, nil
+}
+
+// shoeboxItem returns a key identifying the shoebox item and the date it was collected
+// derived from the URL fragment of 'key' and a boolean value indicating whether they could be derived.
+func shoeboxItem(key string) (string, int64, bool) {
+
+	k, err := keys.Parse(key)
+
+	if err != nil {
+		return "", 0, false
+	}
+
+	group_key := fmt.Sprintf("%s:%d", k.Label, k.Id)
+	return group_key, k.Created, true
+}
@@ -0,0 +1,62 @@
+package sort
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aaronland/go-picturebook/picture"
+	pb_sort "github.com/aaronland/go-picturebook/sort"
+)
+
+func TestShoeboxSorter(t *testing.T) {
+
+	ctx := context.Background()
+
+	sources := []string{
+		"https://static.sfomuseum.org/media/3_c.jpg#v1;label=o;id=3;item=30;created=300;image=3;size=c",
+		"https://static.sfomuseum.org/media/1_front_c.jpg#v1;label=o;id=1;item=10;created=100;size=c",
+		"https://example.com/other.jpg",
+		"https://static.sfomuseum.org/media/2_c.jpg#v1;label=ig;id=2;item=20;created=200;type=instagram",
+		// Keys created before the URL fragment was versioned
+		"https://static.sfomuseum.org/media/1_back_c.jpg#o:1:10:100",
+	}
+
+	pictures := make([]*picture.PictureBookPicture, len(sources))
+
+	for idx, src := range sources {
+		pictures[idx] = &picture.PictureBookPicture{
+			Source: src,
+		}
+	}
+
+	tests := map[string][]int{
+		"shoebox://":            []int{1, 4, 3, 0, 2},
+		"shoebox://?order=desc": []int{0, 3, 1, 4, 2},
+	}
+
+	for uri, expected := range tests {
+
+		s, err := pb_sort.NewSorter(ctx, uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create sorter for %s, %v", uri, err)
+		}
+
+		sorted, err := s.Sort(ctx, nil, pictures)
+
+		if err != nil {
+			t.Fatalf("Failed to sort pictures for %s, %v", uri, err)
+		}
+
+		if len(sorted) != len(expected) {
+			t.Fatalf("Unexpected number of pictures for %s: %d", uri, len(sorted))
+		}
+
+		for idx, offset := range expected {
+
+			if sorted[idx].Source != sources[offset] {
+				t.Fatalf("Unexpected picture at position %d for %s: %s", idx, uri, sorted[idx].Source)
+			}
+		}
+	}
+}
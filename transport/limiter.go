@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// limiters is a map of `rateLimiter` instances shared by all the `RetryTransport` instances in an application,
+// keyed by host and rate limit.
+var limiters = new(sync.Map)
+
+// rateLimiter spaces requests to a host so that no more than a given number are started each second.
+type rateLimiter struct {
+	// interval is the minimum interval between the start of two requests. Zero means there is no limit.
+	interval time.Duration
+	// next is the earliest time the next request may be started.
+	next time.Time
+	mu   *sync.Mutex
+}
+
+// limiterForHost returns the `rateLimiter` instance for 'host' limiting requests to 'rate' requests per second.
+func limiterForHost(host string, rate float64) *rateLimiter {
+
+	if rate <= 0 {
+		return &rateLimiter{}
+	}
+
+	key := fmt.Sprintf("%s#%f", host, rate)
+
+	l := &rateLimiter{
+		interval: time.Duration(float64(time.Second) / rate),
+		mu:       new(sync.Mutex),
+	}
+
+	v, _ := limiters.LoadOrStore(key, l)
+	return v.(*rateLimiter)
+}
+
+// wait blocks until a request may be started or 'ctx' is cancelled.
+func (l *rateLimiter) wait(ctx context.Context) error {
+
+	if l.interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+
+	now := time.Now()
+
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
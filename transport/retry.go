@@ -0,0 +1,361 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryTransport implements the `http.RoundTripper` interface retrying requests which fail with a transient error.
+type RetryTransport struct {
+	http.RoundTripper
+	// base is the underlying `http.RoundTripper` used to perform each attempt.
+	base http.RoundTripper
+	// max_attempts is the maximum number of attempts (including the first) for a request.
+	max_attempts int
+	// backoff is the delay before retrying a request for the first time.
+	backoff time.Duration
+	// max_backoff is the maximum delay before retrying a request.
+	max_backoff time.Duration
+	// rate_limit is the maximum number of requests per second for each host.
+	rate_limit float64
+	// read_timeout is the maximum time to wait between reads of the response body.
+	read_timeout time.Duration
+	// timeout is the maximum time for each attempt of a request, including reading the response body.
+	timeout time.Duration
+	// user_agent is the User-Agent header sent with requests which don't already have one.
+	user_agent string
+}
+
+// NewRetryTransport returns a new `RetryTransport` instance derived from 'opts'.
+func NewRetryTransport(opts *Options) *RetryTransport {
+
+	dialer := &net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	// These are the same settings as http.DefaultTransport, which can not be cloned since it may have been replaced
+	// (for example by an api.Transport instance)
+
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   opts.ConnectTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: opts.ReadTimeout,
+	}
+
+	if opts.Proxy != nil {
+		base.Proxy = http.ProxyURL(opts.Proxy)
+	}
+
+	if opts.Insecure || opts.RootCAs != nil {
+
+		base.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: opts.Insecure,
+			RootCAs:            opts.RootCAs,
+		}
+	}
+
+	t := &RetryTransport{
+		base:         base,
+		max_attempts: max(opts.MaxAttempts, 1),
+		backoff:      opts.Backoff,
+		max_backoff:  opts.MaxBackoff,
+		rate_limit:   opts.RateLimit,
+		read_timeout: opts.ReadTimeout,
+		timeout:      opts.Timeout,
+		user_agent:   opts.UserAgent,
+	}
+
+	return t
+}
+
+// RoundTrip performs 'req' retrying it, after a delay, if it fails with a transient network error (see `isTransientError`) or a
+// 429, 502, 503 or 504 HTTP status code until the maximum number of attempts is reached. The delay is derived from the `Retry-After` header of the response, if
+// present, and otherwise increases exponentially (with jitter) with each attempt. Only GET, HEAD and OPTIONS requests are retried in
+// these cases since other requests may have been (partially) processed. Other requests are only retried if they fail with a 429 or 503
+// HTTP status code and a `Retry-After` header. Requests with a body which can not be rewound are never retried. Once the maximum number of attempts is reached the response (or error) for the final attempt is returned.
+// Each attempt is cancelled if it exceeds the overall timeout or if reading the response body stalls for longer than the read timeout.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	ctx := req.Context()
+
+	logger := slog.Default()
+	logger = logger.With("method", req.Method, "host", req.URL.Host, "path", req.URL.Path)
+
+	can_retry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+
+		err := limiterForHost(req.URL.Host, t.rate_limit).wait(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var attempt_ctx context.Context
+		var cancel context.CancelFunc
+
+		if t.timeout > 0 {
+			attempt_ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		} else {
+			attempt_ctx, cancel = context.WithCancel(ctx)
+		}
+
+		attempt_req := req.Clone(attempt_ctx)
+
+		if attempt > 1 && req.GetBody != nil {
+
+			body, err := req.GetBody()
+
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+
+			attempt_req.Body = body
+		}
+
+		if attempt_req.Header.Get("User-Agent") == "" && t.user_agent != "" {
+			attempt_req.Header.Set("User-Agent", t.user_agent)
+		}
+
+		rsp, err := t.base.RoundTrip(attempt_req)
+
+		if err != nil {
+			cancel()
+		} else {
+			rsp.Body = newTimeoutBody(rsp.Body, t.read_timeout, cancel)
+		}
+
+		if attempt >= t.max_attempts || !can_retry || !isRetryable(req, rsp, err) {
+			return rsp, err
+		}
+
+		delay := t.delay(attempt, rsp)
+
+		if err != nil {
+			logger.Debug("Request failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		} else {
+			logger.Debug("Request failed, retrying", "attempt", attempt, "delay", delay, "status", rsp.StatusCode)
+
+			// Drain (some of) the body so the underlying connection can be reused
+			io.CopyN(io.Discard, rsp.Body, 4096)
+			rsp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+			// pass
+		}
+	}
+}
+
+// delay returns the delay before retrying a request after 'attempt' attempts, the last of which returned 'rsp' (which may be nil).
+func (t *RetryTransport) delay(attempt int, rsp *http.Response) time.Duration {
+
+	if rsp != nil {
+
+		if d, ok := retryAfter(rsp.Header.Get("Retry-After")); ok {
+			return min(d, t.max_backoff)
+		}
+	}
+
+	d := t.backoff
+
+	for i := 1; i < attempt && d < t.max_backoff; i++ {
+		d = d * 2
+	}
+
+	d = min(d, t.max_backoff)
+
+	if d <= 0 {
+		return 0
+	}
+
+	// Equal jitter: somewhere between half and all of the delay
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// isRetryable returns a boolean value indicating whether 'req', which returned 'rsp' and 'err', failed with a transient error.
+func isRetryable(req *http.Request, rsp *http.Response, err error) bool {
+
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		// pass
+	default:
+
+		// Requests which are not idempotent are only retried when the server says it didn't process them
+
+		if err != nil {
+			return false
+		}
+
+		switch rsp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			_, ok := retryAfter(rsp.Header.Get("Retry-After"))
+			return ok
+		default:
+			return false
+		}
+	}
+
+	if err != nil {
+
+		// Retry timeouts for individual attempts but not requests which have been cancelled
+
+		if req.Context().Err() != nil {
+			return false
+		}
+
+		return isTransientError(err)
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTransientError returns a boolean value indicating whether 'err' is a timeout, a network error or a connection which was
+// refused, reset or closed unexpectedly. Permanent failures, for example TLS certificate verification errors, unknown hosts,
+// unsupported schemes or invalid proxy configurations, are not transient.
+func isTransientError(err error) bool {
+
+	var cert_err *tls.CertificateVerificationError
+	var unknown_authority_err x509.UnknownAuthorityError
+	var hostname_err x509.HostnameError
+	var invalid_cert_err x509.CertificateInvalidError
+
+	if errors.As(err, &cert_err) || errors.As(err, &unknown_authority_err) || errors.As(err, &hostname_err) || errors.As(err, &invalid_cert_err) {
+		return false
+	}
+
+	var dns_err *net.DNSError
+
+	if errors.As(err, &dns_err) && dns_err.IsNotFound {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED):
+		return true
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+
+	var net_err net.Error
+
+	return errors.As(err, &net_err)
+}
+
+// retryAfter returns the delay derived from the value of a `Retry-After` header, which may be a number of seconds or
+// a HTTP date, and a boolean value indicating whether it could be derived.
+func retryAfter(v string) (time.Duration, bool) {
+
+	if v == "" {
+		return 0, false
+	}
+
+	secs, err := strconv.Atoi(v)
+
+	if err == nil {
+
+		if secs < 0 {
+			return 0, false
+		}
+
+		return time.Duration(secs) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+
+	if err != nil {
+		return 0, false
+	}
+
+	return max(time.Until(t), 0), true
+}
+
+// timeoutBody wraps the body of a response cancelling the request it belongs to when it is closed or when reading
+// from it stalls for longer than a given timeout.
+type timeoutBody struct {
+	io.ReadCloser
+	// timer cancels the request when reading from the body stalls. It is nil if there is no timeout.
+	timer *time.Timer
+	// timeout is the maximum time to wait between reads.
+	timeout time.Duration
+	// cancel cancels the request the body belongs to.
+	cancel context.CancelFunc
+}
+
+// newTimeoutBody returns a new `timeoutBody` instance wrapping 'body' which calls 'cancel' when closed or when reading
+// from it stalls for longer than 'timeout'. If 'timeout' is zero there is no limit.
+func newTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) io.ReadCloser {
+
+	b := &timeoutBody{
+		ReadCloser: body,
+		timeout:    timeout,
+		cancel:     cancel,
+	}
+
+	if timeout > 0 {
+		b.timer = time.AfterFunc(timeout, cancel)
+	}
+
+	return b
+}
+
+// Read reads from the underlying body resetting the read timeout (or stopping it once the body has been read).
+func (b *timeoutBody) Read(p []byte) (int, error) {
+
+	n, err := b.ReadCloser.Read(p)
+
+	if b.timer != nil {
+
+		if err == nil {
+			b.timer.Reset(b.timeout)
+		} else {
+			b.timer.Stop()
+		}
+	}
+
+	return n, err
+}
+
+// Close closes the underlying body and cancels the request it belongs to.
+func (b *timeoutBody) Close() error {
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
@@ -0,0 +1,269 @@
+// package transport provides the HTTP layer shared by the SFO Museum API and media requests in this package. Requests
+// which fail with a transient error (a network error or a 429, 502, 503 or 504 HTTP status code) are retried, with
+// exponential backoff and jitter, honouring any `Retry-After` headers, up to a maximum number of attempts. Requests may
+// also be limited to a maximum number of requests per second for each host. Every attempt is subject to connect, read
+// and overall timeouts and may be sent through a HTTP(S) proxy, trusting additional certificate authorities, with an
+// identifying User-Agent header.
+package transport
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DEFAULT_MAX_ATTEMPTS is the default maximum number of attempts (including the first) for a request.
+const DEFAULT_MAX_ATTEMPTS int = 4
+
+// DEFAULT_BACKOFF is the default delay before retrying a request for the first time. Subsequent delays are doubled.
+const DEFAULT_BACKOFF time.Duration = 500 * time.Millisecond
+
+// DEFAULT_MAX_BACKOFF is the default maximum delay before retrying a request.
+const DEFAULT_MAX_BACKOFF time.Duration = 30 * time.Second
+
+// DEFAULT_CONNECT_TIMEOUT is the default maximum time to establish a connection, including the TLS handshake.
+const DEFAULT_CONNECT_TIMEOUT time.Duration = 10 * time.Second
+
+// DEFAULT_READ_TIMEOUT is the default maximum time to wait for the response headers or between reads of the response body.
+const DEFAULT_READ_TIMEOUT time.Duration = 30 * time.Second
+
+// DEFAULT_TIMEOUT is the default maximum time for each attempt of a request, including reading the response body.
+const DEFAULT_TIMEOUT time.Duration = 5 * time.Minute
+
+// DEFAULT_USER_AGENT is the default User-Agent header sent with each request.
+const DEFAULT_USER_AGENT string = "go-picturebook-sfomuseum (+https://github.com/sfomuseum/go-picturebook-sfomuseum)"
+
+// Options defines configuration options for the `http.RoundTripper` instances created by this package.
+type Options struct {
+	// The maximum number of attempts (including the first) for a request.
+	MaxAttempts int
+	// The delay before retrying a request for the first time. Subsequent delays are doubled (with jitter) up to `MaxBackoff`.
+	Backoff time.Duration
+	// The maximum delay before retrying a request. This includes delays requested by a `Retry-After` header.
+	MaxBackoff time.Duration
+	// The maximum number of requests per second for each host. Zero means there is no limit.
+	RateLimit float64
+	// The maximum time to establish a connection, including the TLS handshake. Zero means there is no limit.
+	ConnectTimeout time.Duration
+	// The maximum time to wait for the response headers or between reads of the response body. Zero means there is no limit.
+	ReadTimeout time.Duration
+	// The maximum time for each attempt of a request, including reading the response body. Zero means there is no limit.
+	Timeout time.Duration
+	// An optional HTTP(S) proxy to send requests through. If nil the proxy is derived from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables.
+	Proxy *url.URL
+	// An optional pool of certificate authorities to trust. If nil the system pool is used.
+	RootCAs *x509.CertPool
+	// The User-Agent header sent with requests which don't already have one.
+	UserAgent string
+	// A boolean flag signaling that TLS verification should be skipped.
+	Insecure bool
+}
+
+// DefaultOptions returns a new `Options` instance with default values.
+func DefaultOptions() *Options {
+
+	opts := &Options{
+		MaxAttempts:    DEFAULT_MAX_ATTEMPTS,
+		Backoff:        DEFAULT_BACKOFF,
+		MaxBackoff:     DEFAULT_MAX_BACKOFF,
+		ConnectTimeout: DEFAULT_CONNECT_TIMEOUT,
+		ReadTimeout:    DEFAULT_READ_TIMEOUT,
+		Timeout:        DEFAULT_TIMEOUT,
+		UserAgent:      DEFAULT_USER_AGENT,
+	}
+
+	return opts
+}
+
+// OptionsWithQuery returns a new `Options` instance derived from the following query parameters in 'q':
+// - `?max_attempts={N}` The maximum number of attempts (including the first) for a request. Default is 4.
+// - `?backoff={DURATION}` The delay before retrying a request for the first time. Subsequent delays are doubled (with jitter). Default is "500ms".
+// - `?max_backoff={DURATION}` The maximum delay before retrying a request, including delays requested by a `Retry-After` header. Default is "30s".
+// - `?rate_limit={N}` The maximum number of requests per second for each host. Default is 0 (no limit).
+// - `?connect_timeout={DURATION}` The maximum time to establish a connection, including the TLS handshake. Default is "10s".
+// - `?read_timeout={DURATION}` The maximum time to wait for the response headers or between reads of the response body. Default is "30s".
+// - `?timeout={DURATION}` The maximum time for each attempt of a request, including reading the response body. Default is "5m".
+// - `?proxy={URL}` An optional HTTP(S) proxy to send requests through. If empty the proxy is derived from the HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables.
+// - `?ca_bundle={PATH}` An optional path to a file containing one or more PEM-encoded certificate authorities to trust in addition to the
+// system certificate authorities (for example those of a TLS-inspecting proxy).
+// - `?user_agent={STRING}` The User-Agent header sent with each request. Default is `DEFAULT_USER_AGENT`.
+//
+// Timeouts of "0" mean there is no limit.
+//
+// Note that the `?insecure=` parameter is not read since skipping TLS verification is only supported for API endpoints. See `api.NewTransportWithQuery` for details.
+func OptionsWithQuery(q url.Values) (*Options, error) {
+
+	opts := DefaultOptions()
+
+	if q.Has("max_attempts") {
+
+		v, err := strconv.Atoi(q.Get("max_attempts"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?max_attempts= parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid ?max_attempts= parameter, must be greater than zero")
+		}
+
+		opts.MaxAttempts = v
+	}
+
+	if q.Has("backoff") {
+
+		v, err := time.ParseDuration(q.Get("backoff"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?backoff= parameter, %w", err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid ?backoff= parameter, must not be negative")
+		}
+
+		opts.Backoff = v
+	}
+
+	if q.Has("max_backoff") {
+
+		v, err := time.ParseDuration(q.Get("max_backoff"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?max_backoff= parameter, %w", err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid ?max_backoff= parameter, must not be negative")
+		}
+
+		opts.MaxBackoff = v
+	}
+
+	if q.Has("rate_limit") {
+
+		v, err := strconv.ParseFloat(q.Get("rate_limit"), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?rate_limit= parameter, %w", err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid ?rate_limit= parameter, must not be negative")
+		}
+
+		opts.RateLimit = v
+	}
+
+	timeouts := map[string]*time.Duration{
+		"connect_timeout": &opts.ConnectTimeout,
+		"read_timeout":    &opts.ReadTimeout,
+		"timeout":         &opts.Timeout,
+	}
+
+	for k, d := range timeouts {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := time.ParseDuration(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?%s= parameter, %w", k, err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, must not be negative", k)
+		}
+
+		*d = v
+	}
+
+	if q.Has("proxy") {
+
+		v, err := url.Parse(q.Get("proxy"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse ?proxy= parameter, %w", err)
+		}
+
+		switch v.Scheme {
+		case "http", "https", "socks5":
+			// pass
+		default:
+			return nil, fmt.Errorf("Invalid ?proxy= parameter, unsupported scheme '%s'", v.Scheme)
+		}
+
+		opts.Proxy = v
+	}
+
+	if q.Has("ca_bundle") {
+
+		pool, err := loadCABundle(q.Get("ca_bundle"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load ?ca_bundle= parameter, %w", err)
+		}
+
+		opts.RootCAs = pool
+	}
+
+	if q.Has("user_agent") {
+		opts.UserAgent = q.Get("user_agent")
+	}
+
+	return opts, nil
+}
+
+// loadCABundle returns a new `x509.CertPool` instance containing the system certificate authorities and the
+// PEM-encoded certificate authorities in the file at 'path'.
+func loadCABundle(path string) (*x509.CertPool, error) {
+
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(body) {
+		return nil, fmt.Errorf("%s does not contain any PEM-encoded certificates", path)
+	}
+
+	return pool, nil
+}
+
+// NewHTTPClient returns a new `http.Client` instance whose requests are performed by a `RetryTransport` instance derived from 'opts'.
+func NewHTTPClient(opts *Options) *http.Client {
+
+	http_client := &http.Client{
+		Transport: NewRetryTransport(opts),
+	}
+
+	return http_client
+}
+
+// NewHTTPClientWithQuery returns a new `http.Client` instance whose requests are performed by a `RetryTransport` instance derived from
+// the query parameters in 'q'. See `OptionsWithQuery` for details.
+func NewHTTPClientWithQuery(q url.Values) (*http.Client, error) {
+
+	opts, err := OptionsWithQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHTTPClient(opts), nil
+}
@@ -0,0 +1,412 @@
+package transport
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOptionsWithQuery(t *testing.T) {
+
+	q, _ := url.ParseQuery("max_attempts=2&backoff=10ms&max_backoff=1s&rate_limit=2.5")
+
+	opts, err := OptionsWithQuery(q)
+
+	if err != nil {
+		t.Fatalf("Failed to derive options, %v", err)
+	}
+
+	if opts.MaxAttempts != 2 || opts.Backoff != 10*time.Millisecond || opts.MaxBackoff != time.Second || opts.RateLimit != 2.5 {
+		t.Fatalf("Unexpected options: %v", opts)
+	}
+
+	invalid := []string{
+		"max_attempts=0",
+		"max_attempts=many",
+		"backoff=soon",
+		"max_backoff=-1s",
+		"rate_limit=-1",
+	}
+
+	for _, str_q := range invalid {
+
+		q, _ := url.ParseQuery(str_q)
+
+		_, err := OptionsWithQuery(q)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str_q)
+		}
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+
+	var count atomic.Int32
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		switch count.Add(1) {
+		case 1:
+			rsp.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			rsp.Header().Set("Retry-After", "0")
+			rsp.WriteHeader(http.StatusTooManyRequests)
+		default:
+			rsp.Write([]byte("OK"))
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	opts := &Options{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}
+
+	rsp, err := NewHTTPClient(opts).Get(server.URL)
+
+	if err != nil {
+		t.Fatalf("Failed to execute request, %v", err)
+	}
+
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK || count.Load() != 3 {
+		t.Fatalf("Unexpected response after %d attempts: %d", count.Load(), rsp.StatusCode)
+	}
+
+	// The response for the final attempt is returned once the maximum number of attempts is reached
+
+	count.Store(0)
+	opts.MaxAttempts = 2
+
+	rsp, err = NewHTTPClient(opts).Get(server.URL)
+
+	if err != nil {
+		t.Fatalf("Failed to execute request, %v", err)
+	}
+
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusTooManyRequests || count.Load() != 2 {
+		t.Fatalf("Unexpected response after %d attempts: %d", count.Load(), rsp.StatusCode)
+	}
+
+	// Other errors are not retried
+
+	not_found := httptest.NewServer(http.NotFoundHandler())
+	defer not_found.Close()
+
+	count.Store(0)
+
+	rsp, err = NewHTTPClient(opts).Get(not_found.URL)
+
+	if err != nil {
+		t.Fatalf("Failed to execute request, %v", err)
+	}
+
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Unexpected response: %d", rsp.StatusCode)
+	}
+}
+
+func TestRetryTransportMethods(t *testing.T) {
+
+	var count atomic.Int32
+	var retry_after atomic.Bool
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if count.Add(1) == 1 {
+
+			if retry_after.Load() {
+				rsp.Header().Set("Retry-After", "0")
+			}
+
+			rsp.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		rsp.Write([]byte("OK"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	opts := &Options{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}
+
+	tests := []struct {
+		method      string
+		retry_after bool
+		attempts    int32
+	}{
+		{http.MethodHead, false, 2},
+		{http.MethodOptions, false, 2},
+		{http.MethodPost, false, 1},
+		{http.MethodPut, false, 1},
+		{http.MethodPost, true, 2},
+	}
+
+	for _, test := range tests {
+
+		count.Store(0)
+		retry_after.Store(test.retry_after)
+
+		req, err := http.NewRequest(test.method, server.URL, strings.NewReader("body"))
+
+		if err != nil {
+			t.Fatalf("Failed to create request, %v", err)
+		}
+
+		rsp, err := NewHTTPClient(opts).Do(req)
+
+		if err != nil {
+			t.Fatalf("Failed to execute %s request, %v", test.method, err)
+		}
+
+		rsp.Body.Close()
+
+		if count.Load() != test.attempts {
+			t.Fatalf("Unexpected number of attempts for %s request (Retry-After %t): %d", test.method, test.retry_after, count.Load())
+		}
+	}
+}
+
+func TestRetryTransportPermanentErrors(t *testing.T) {
+
+	// The TLS server's certificate isn't trusted by the client
+
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	opts := &Options{
+		MaxAttempts: 3,
+		Backoff:     time.Second,
+		MaxBackoff:  time.Second,
+	}
+
+	for _, uri := range []string{server.URL, "gopher://example.com/"} {
+
+		t1 := time.Now()
+
+		_, err := NewHTTPClient(opts).Get(uri)
+
+		if err == nil {
+			t.Fatalf("Expected request for %s to fail", uri)
+		}
+
+		if time.Since(t1) >= 500*time.Millisecond {
+			t.Fatalf("Expected request for %s to fail without being retried", uri)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+
+	tr := NewRetryTransport(&Options{
+		MaxAttempts: 10,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  time.Second,
+	})
+
+	for attempt := 1; attempt < 10; attempt++ {
+
+		d := tr.delay(attempt, nil)
+
+		if d > time.Second || d < 50*time.Millisecond {
+			t.Fatalf("Unexpected delay for attempt %d: %v", attempt, d)
+		}
+	}
+
+	rsp := &http.Response{
+		Header: http.Header{},
+	}
+
+	rsp.Header.Set("Retry-After", "60")
+
+	if d := tr.delay(1, rsp); d != time.Second {
+		t.Fatalf("Expected Retry-After delay to be capped: %v", d)
+	}
+
+	rsp.Header.Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+
+	if d := tr.delay(1, rsp); d != 0 {
+		t.Fatalf("Unexpected Retry-After delay for date in the past: %v", d)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.Write([]byte("OK"))
+	}))
+
+	defer server.Close()
+
+	opts := DefaultOptions()
+	opts.RateLimit = 20
+
+	http_client := NewHTTPClient(opts)
+
+	t1 := time.Now()
+
+	for i := 0; i < 5; i++ {
+
+		rsp, err := http_client.Get(server.URL)
+
+		if err != nil {
+			t.Fatalf("Failed to execute request, %v", err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	// 5 requests at 20 requests per second should take at least 200ms
+
+	if time.Since(t1) < 195*time.Millisecond {
+		t.Fatalf("Expected requests to be rate limited: %v", time.Since(t1))
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if req.Header.Get("User-Agent") != "testing" {
+			rsp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		rsp.Write([]byte("OK"))
+		rsp.(http.Flusher).Flush()
+
+		// Stall until the client gives up
+
+		<-req.Context().Done()
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	opts := DefaultOptions()
+	opts.MaxAttempts = 1
+	opts.ReadTimeout = 50 * time.Millisecond
+	opts.UserAgent = "testing"
+
+	rsp, err := NewHTTPClient(opts).Get(server.URL)
+
+	if err != nil {
+		t.Fatalf("Failed to execute request, %v", err)
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status code: %d", rsp.StatusCode)
+	}
+
+	_, err = io.ReadAll(rsp.Body)
+
+	if err == nil {
+		t.Fatalf("Expected stalled response body to time out")
+	}
+
+	// Overall timeout
+
+	opts.ReadTimeout = 0
+	opts.Timeout = 50 * time.Millisecond
+
+	rsp, err = NewHTTPClient(opts).Get(server.URL)
+
+	if err != nil {
+		t.Fatalf("Failed to execute request, %v", err)
+	}
+
+	defer rsp.Body.Close()
+
+	_, err = io.ReadAll(rsp.Body)
+
+	if err == nil {
+		t.Fatalf("Expected request to time out")
+	}
+}
+
+func TestOptionsWithQueryTLSAndProxy(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.Write([]byte("OK"))
+	}))
+
+	defer server.Close()
+
+	ca_bundle := filepath.Join(t.TempDir(), "ca.pem")
+
+	pem_cert := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+
+	err := os.WriteFile(ca_bundle, pem_cert, 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write CA bundle, %v", err)
+	}
+
+	q := url.Values{}
+	q.Set("ca_bundle", ca_bundle)
+	q.Set("proxy", "http://proxy.example.com:3128")
+
+	opts, err := OptionsWithQuery(q)
+
+	if err != nil {
+		t.Fatalf("Failed to derive options, %v", err)
+	}
+
+	if opts.Proxy == nil || opts.Proxy.Host != "proxy.example.com:3128" {
+		t.Fatalf("Unexpected proxy: %v", opts.Proxy)
+	}
+
+	opts.Proxy = nil
+
+	rsp, err := NewHTTPClient(opts).Get(server.URL)
+
+	if err != nil {
+		t.Fatalf("Failed to execute request trusting CA bundle, %v", err)
+	}
+
+	rsp.Body.Close()
+
+	invalid := []string{
+		"ca_bundle=" + url.QueryEscape(filepath.Join(t.TempDir(), "missing.pem")),
+		"proxy=ftp://proxy.example.com",
+		"timeout=forever",
+	}
+
+	for _, str_q := range invalid {
+
+		q, _ := url.ParseQuery(str_q)
+
+		_, err := OptionsWithQuery(q)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str_q)
+		}
+	}
+}